@@ -0,0 +1,294 @@
+package monk
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// coercionError is what coerce fails with. Validate reports
+// it against the field as "field 'x' expects 'int' but received y"
+type coercionError struct {
+	Expected reflect.Type
+	Received interface{}
+}
+
+func (e coercionError) Error() string {
+	return fmt.Sprintf("expects '%s' but received %v", e.Expected, e.Received)
+}
+
+// coerce converts the value into one of the given type. Strings (as
+// received from forms and query strings) are parsed, and the generic
+// values decoded from JSON (float64, []interface{}, map[string]interface{})
+// are converted. It handles bool, int*, uint*, float*, time.Time (in any
+// of the layouts dateparse understands), primitive.ObjectID, pointers
+// (the value pointed to is returned), slices (from JSON arrays, or comma
+// separated strings) and maps. Values of other types are returned as is
+func coerce(t reflect.Type, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	t = do.TypeDereference(t)
+
+	// Already of the type
+	vt := reflect.TypeOf(v)
+	if vt == t {
+		return v, nil
+	}
+	if vt.Kind() == reflect.Ptr && vt.Elem() == t {
+		rv := reflect.ValueOf(v)
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Elem().Interface(), nil
+	}
+
+	fail := coercionError{Expected: t, Received: v}
+	str, isStr := v.(string)
+
+	switch {
+	case t == timeType:
+		if !isStr {
+			return nil, fail
+		}
+		at, err := dateparse.ParseAny(strings.TrimSpace(str))
+		if err != nil {
+			return nil, fail
+		}
+		return at, nil
+	case t == objectIDType:
+		if !isStr {
+			return nil, fail
+		}
+		id, err := primitive.ObjectIDFromHex(strings.TrimSpace(str))
+		if err != nil {
+			return nil, fail
+		}
+		return id, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if !isStr {
+			return nil, fail
+		}
+		return reflect.ValueOf(str).Convert(t).Interface(), nil
+
+	case reflect.Bool:
+		if !isStr {
+			return nil, fail
+		}
+		switch strings.TrimSpace(str) {
+		case "1", "yes", "true", "Y", "y", "on":
+			return reflect.ValueOf(true).Convert(t).Interface(), nil
+		case "0", "no", "false", "N", "n", "off", "":
+			return reflect.ValueOf(false).Convert(t).Interface(), nil
+		}
+		return nil, fail
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		rv := reflect.ValueOf(v)
+		switch {
+		case isStr:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(str), 10, t.Bits())
+			if err != nil {
+				return nil, fail
+			}
+			i = parsed
+		case isIntKind(rv.Kind()):
+			i = rv.Int()
+		case isUintKind(rv.Kind()):
+			if rv.Uint() > math.MaxInt64 {
+				return nil, fail
+			}
+			i = int64(rv.Uint())
+		case isFloatKind(rv.Kind()):
+			// Floats beyond ±2^63 do not fit, and 2^63
+			// itself is the first of them
+			f := rv.Float()
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= 1<<63 {
+				return nil, fail
+			}
+			i = int64(f)
+		default:
+			return nil, fail
+		}
+		if reflect.New(t).Elem().OverflowInt(i) {
+			return nil, fail
+		}
+		return reflect.ValueOf(i).Convert(t).Interface(), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		rv := reflect.ValueOf(v)
+		switch {
+		case isStr:
+			parsed, err := strconv.ParseUint(strings.TrimSpace(str), 10, t.Bits())
+			if err != nil {
+				return nil, fail
+			}
+			u = parsed
+		case isUintKind(rv.Kind()):
+			u = rv.Uint()
+		case isIntKind(rv.Kind()):
+			if rv.Int() < 0 {
+				return nil, fail
+			}
+			u = uint64(rv.Int())
+		case isFloatKind(rv.Kind()):
+			f := rv.Float()
+			if f != math.Trunc(f) || f < 0 || f >= 1<<64 {
+				return nil, fail
+			}
+			u = uint64(f)
+		default:
+			return nil, fail
+		}
+		if reflect.New(t).Elem().OverflowUint(u) {
+			return nil, fail
+		}
+		return reflect.ValueOf(u).Convert(t).Interface(), nil
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if isStr {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(str), t.Bits())
+			if err != nil {
+				return nil, fail
+			}
+			f = parsed
+		} else if num, isNum := asFloat(v); isNum {
+			f = num
+		} else {
+			return nil, fail
+		}
+		return reflect.ValueOf(f).Convert(t).Interface(), nil
+
+	case reflect.Slice:
+		if isNestedType(t.Elem()) {
+			return v, nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			if isStr {
+				return []byte(str), nil
+			}
+			return v, nil
+		}
+		items, err := coercibleItems(v)
+		if err != nil {
+			return nil, fail
+		}
+		list := reflect.MakeSlice(t, 0, len(items))
+		for _, item := range items {
+			val, err := coerce(t.Elem(), item)
+			if err != nil {
+				return nil, fail
+			}
+			list = reflect.Append(list, coercedValue(t.Elem(), val))
+		}
+		return list.Interface(), nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || isNestedType(t.Elem()) {
+			return v, nil
+		}
+		entries, isMap := asMap(v)
+		if isStr {
+			entries = map[string]interface{}{}
+			isMap = json.Unmarshal([]byte(str), &entries) == nil
+		}
+		if !isMap {
+			return nil, fail
+		}
+		m := reflect.MakeMapWithSize(t, len(entries))
+		for key, entry := range entries {
+			val, err := coerce(t.Elem(), entry)
+			if err != nil {
+				return nil, fail
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), coercedValue(t.Elem(), val))
+		}
+		return m.Interface(), nil
+	}
+
+	// Sub-documents, interfaces, ...
+	return v, nil
+}
+
+// coercibleItems returns the items of the value (a slice, a JSON
+// array, or comma separated values) to be coerced one by one
+func coercibleItems(v interface{}) ([]interface{}, error) {
+	if str, isStr := v.(string); isStr {
+		str = strings.TrimSpace(str)
+		if strings.HasPrefix(str, "[") {
+			items := []interface{}{}
+			err := json.Unmarshal([]byte(str), &items)
+			return items, err
+		}
+		items := []interface{}{}
+		for _, part := range strings.Split(str, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				items = append(items, part)
+			}
+		}
+		return items, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%v is not a list", v)
+	}
+	items := []interface{}{}
+	for i := 0; i < rv.Len(); i++ {
+		items = append(items, rv.Index(i).Interface())
+	}
+	return items, nil
+}
+
+// coercedValue wraps the coerced value for storing in a
+// slice or map of type t (eg. taking its address for *int)
+func coercedValue(t reflect.Type, val interface{}) reflect.Value {
+	if val == nil {
+		return reflect.Zero(t)
+	}
+	rv := reflect.ValueOf(val)
+	if t.Kind() == reflect.Ptr && rv.Type() == t.Elem() {
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(rv)
+		return ptr
+	}
+	if t.Kind() == reflect.Interface || !rv.Type().ConvertibleTo(t) {
+		return rv
+	}
+	return rv.Convert(t)
+}
+
+// isNestedType tells if values of the type are sub-documents,
+// which are validated field by field rather than coerced
+func isNestedType(t reflect.Type) bool {
+	t = do.TypeDereference(t)
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k == reflect.Int || k == reflect.Int8 || k == reflect.Int16 || k == reflect.Int32 || k == reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k == reflect.Uint || k == reflect.Uint8 || k == reflect.Uint16 || k == reflect.Uint32 || k == reflect.Uint64
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
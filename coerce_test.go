@@ -0,0 +1,125 @@
+package monk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCoerce(t *testing.T) {
+
+	id := primitive.NewObjectID()
+	seven := 7
+
+	cases := []struct {
+		typ  interface{}
+		in   interface{}
+		want interface{}
+	}{
+		{true, "yes", true},
+		{true, "off", false},
+		{int(0), "42", 42},
+		{int8(0), float64(-8), int8(-8)},
+		{int64(0), "9007199254740993", int64(9007199254740993)},
+		{int64(0), int64(9007199254740993), int64(9007199254740993)},
+		{int64(0), uint64(9007199254740993), int64(9007199254740993)},
+		{uint64(0), int64(9007199254740993), uint64(9007199254740993)},
+		{int32(0), int64(-5), int32(-5)},
+		{uint16(0), "65535", uint16(65535)},
+		{uint(0), float64(3), uint(3)},
+		{float32(0), "1.5", float32(1.5)},
+		{float64(0), 2, float64(2)},
+		{time.Time{}, "2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Time{}, "Jan 2, 2024", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{primitive.ObjectID{}, id.Hex(), id},
+		{(*int)(nil), "7", 7},
+		{(*int)(nil), &seven, 7},
+		{[]int{}, "1, 2,3", []int{1, 2, 3}},
+		{[]int{}, "[1,2]", []int{1, 2}},
+		{[]float64{}, []interface{}{float64(1), "2.5"}, []float64{1, 2.5}},
+		{[]*int{}, []interface{}{"7"}, []*int{&seven}},
+		{[]string{}, "a,b", []string{"a", "b"}},
+		{map[string]int{}, `{"a":1}`, map[string]int{"a": 1}},
+		{map[string]int{}, map[string]interface{}{"a": "2"}, map[string]int{"a": 2}},
+		{map[string]interface{}{}, map[string]interface{}{"a": "2"}, map[string]interface{}{"a": "2"}},
+		{"", "abc", "abc"},
+	}
+	for _, c := range cases {
+		out, err := coerce(reflect.TypeOf(c.typ), c.in)
+		assert.Nil(t, err, "%T from %v", c.typ, c.in)
+		assert.Equal(t, c.want, out, "%T from %v", c.typ, c.in)
+	}
+
+	failures := []struct {
+		typ interface{}
+		in  interface{}
+	}{
+		{true, "maybe"},
+		{int(0), "4x"},
+		{int8(0), "300"},
+		{int(0), 1.5},
+		{uint(0), "-1"},
+		{uint8(0), float64(256)},
+		{int64(0), float64(1 << 63)},
+		{uint64(0), float64(1 << 64)},
+		{int8(0), int64(128)},
+		{uint(0), int(-1)},
+		{int64(0), uint64(1 << 63)},
+		{float64(0), "one"},
+		{time.Time{}, "not a date"},
+		{primitive.ObjectID{}, "123"},
+		{[]int{}, "1,b"},
+		{map[string]int{}, "not json"},
+		{"", 5},
+	}
+	for _, c := range failures {
+		_, err := coerce(reflect.TypeOf(c.typ), c.in)
+		assert.NotNil(t, err, "%T from %v", c.typ, c.in)
+	}
+
+	_, err := coerce(reflect.TypeOf(0), "4x")
+	assert.Equal(t, "expects 'int' but received 4x", err.Error())
+}
+
+func TestValidateCoercion(t *testing.T) {
+
+	type coerceModel struct {
+		MongoStore
+		Count   uint32             `json:"count"`
+		Ratio   float64            `json:"ratio"`
+		Due     *time.Time         `json:"due"`
+		Owner   primitive.ObjectID `json:"owner"`
+		Tags    []string           `json:"tags"`
+		Weights map[string]int     `json:"weights"`
+	}
+
+	data := do.Map{
+		"count":   "12",
+		"ratio":   "0.5",
+		"due":     "2024-03-04T05:06:07Z",
+		"owner":   "5f1d7f5b8f1b2c3d4e5f6a7b",
+		"tags":    "a,b",
+		"weights": map[string]interface{}{"x": float64(1)},
+	}
+	ok, issues := Validate(coerceModel{}, INSERT, data)
+	assert.True(t, ok, issues)
+	assert.Equal(t, uint32(12), data["count"])
+	assert.Equal(t, 0.5, data["ratio"])
+	assert.Equal(t, time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC), data["due"])
+	assert.IsType(t, primitive.ObjectID{}, data["owner"])
+	assert.Equal(t, []string{"a", "b"}, data["tags"])
+	assert.Equal(t, map[string]int{"x": 1}, data["weights"])
+
+	// The same messages, whichever way validation is run
+	data = do.Map{"count": "-1"}
+	ok, issues = Validate(coerceModel{}, UPDATE, data)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"field 'count' expects 'uint32' but received -1"}, issues["count"])
+
+	refs := convertFieldType(coerceModel{}, UPDATE, do.Map{"count": "-1"})
+	assert.Equal(t, "field 'count' expects 'uint32' but received -1", refs[0].Message)
+}
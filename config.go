@@ -0,0 +1,163 @@
+package monk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	CONFIGURATION
+
+	The connection details of an environment are resolved from
+	(the first one that is set up wins):
+
+	1. a resolver function, registered via SetConfigResolver
+	2. a config file (yaml or json), loaded via LoadConfigFile,
+	   or named by the MONK_CONFIG_FILE env variable:
+
+		default:
+			conn_str: mongodb://127.0.0.1:27017/
+			db: monk
+		environments:
+			<env-uuid>:
+				db_suffix: abc
+
+	   with the MONK_CONN_STR, MONK_DB, MONK_DB_SUFFIX and
+	   MONK_COLL_SUFFIX env variables overriding it
+*/
+
+// Config holds the connection details of an environment
+type Config struct {
+	ConnStr    string `json:"conn_str" yaml:"conn_str"`
+	DB         string `json:"db" yaml:"db"`
+	DBSuffix   string `json:"db_suffix" yaml:"db_suffix"`
+	CollSuffix string `json:"coll_suffix" yaml:"coll_suffix"`
+}
+
+// merge overlays the non empty values of other
+func (c Config) merge(other Config) Config {
+	if other.ConnStr != "" {
+		c.ConnStr = other.ConnStr
+	}
+	if other.DB != "" {
+		c.DB = other.DB
+	}
+	if other.DBSuffix != "" {
+		c.DBSuffix = other.DBSuffix
+	}
+	if other.CollSuffix != "" {
+		c.CollSuffix = other.CollSuffix
+	}
+	return c
+}
+
+// ConfigFile is the layout of the config file
+type ConfigFile struct {
+	Default      Config            `json:"default" yaml:"default"`
+	Environments map[string]Config `json:"environments" yaml:"environments"`
+}
+
+// ConfigResolver returns the connection details of an environment
+type ConfigResolver func(envUUID string) (Config, error)
+
+var config = struct {
+	sync.RWMutex
+	resolver ConfigResolver
+	file     *ConfigFile
+}{}
+
+// SetConfigResolver registers the function that resolves the connection
+// details of environments. It takes precedence over files and env variables
+func SetConfigResolver(fn ConfigResolver) {
+	config.Lock()
+	defer config.Unlock()
+	config.resolver = fn
+}
+
+// LoadConfigFile reads connection details from a yaml or json file
+func LoadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	file := ConfigFile{}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+
+	config.Lock()
+	defer config.Unlock()
+	config.file = &file
+	return nil
+}
+
+// ResolveConfig returns the connection details of the environment
+// (pass "" for the defaults)
+func ResolveConfig(envUUID string) (Config, error) {
+
+	config.RLock()
+	resolver, file := config.resolver, config.file
+	config.RUnlock()
+
+	c := Config{}
+	if resolver != nil {
+		var err error
+		if c, err = resolver(envUUID); err != nil {
+			return c, err
+		}
+	} else {
+		var err error
+		if c, err = resolveFromFileAndEnv(file, envUUID); err != nil {
+			return c, err
+		}
+	}
+
+	if c.ConnStr == "" {
+		return c, errors.New("connection string is not configured")
+	}
+
+	return c, nil
+}
+
+func resolveFromFileAndEnv(file *ConfigFile, envUUID string) (Config, error) {
+
+	if file == nil {
+		if path := os.Getenv("MONK_CONFIG_FILE"); path != "" {
+			if err := LoadConfigFile(path); err != nil {
+				return Config{}, err
+			}
+			config.RLock()
+			file = config.file
+			config.RUnlock()
+		}
+	}
+
+	c := Config{}
+	if file != nil {
+		c = file.Default
+		if envUUID != "" {
+			c = c.merge(file.Environments[envUUID])
+		}
+	}
+
+	c = c.merge(Config{
+		ConnStr:    os.Getenv("MONK_CONN_STR"),
+		DB:         os.Getenv("MONK_DB"),
+		DBSuffix:   os.Getenv("MONK_DB_SUFFIX"),
+		CollSuffix: os.Getenv("MONK_COLL_SUFFIX"),
+	})
+
+	return c, nil
+}
@@ -0,0 +1,84 @@
+package monk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetConfig() {
+	config.Lock()
+	defer config.Unlock()
+	config.resolver = nil
+	config.file = nil
+}
+
+func TestResolveConfigFromFile(t *testing.T) {
+	defer resetConfig()
+
+	path := filepath.Join(t.TempDir(), "monk.yaml")
+	os.WriteFile(path, []byte(`
+default:
+  conn_str: mongodb://127.0.0.1:27017/
+  db: monk
+environments:
+  env-1:
+    db_suffix: one
+`), 0644)
+	assert.Nil(t, LoadConfigFile(path))
+
+	mc, err := NewMongoConnToDB("env-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "mongodb://127.0.0.1:27017/", mc.ConnStr)
+	assert.Equal(t, "monk", mc.DB)
+	assert.Equal(t, "one", mc.DBSuffix)
+
+	// Env variables override the file
+	t.Setenv("MONK_DB", "other")
+	mc, err = NewMongoConnToColl("env-2", "coll")
+	assert.Nil(t, err)
+	assert.Equal(t, "other", mc.DB)
+	assert.Equal(t, "", mc.DBSuffix)
+	assert.Equal(t, "coll", mc.Coll)
+}
+
+func TestResolveConfigFromJsonFile(t *testing.T) {
+	defer resetConfig()
+
+	path := filepath.Join(t.TempDir(), "monk.json")
+	os.WriteFile(path, []byte(`{"default": {"conn_str": "mongodb://json/", "db": "monk"}}`), 0644)
+	t.Setenv("MONK_CONFIG_FILE", path)
+
+	connStr, err := GetConnString()
+	assert.Nil(t, err)
+	assert.Equal(t, "mongodb://json/", connStr)
+}
+
+func TestResolveConfigFromResolver(t *testing.T) {
+	defer resetConfig()
+
+	t.Setenv("MONK_CONN_STR", "mongodb://env/")
+	SetConfigResolver(func(envUUID string) (Config, error) {
+		if envUUID == "" {
+			return Config{}, errors.New("unknown environment")
+		}
+		return Config{ConnStr: "mongodb://resolved/", DB: "db-" + envUUID}, nil
+	})
+
+	mc, err := NewMongoConnToDB("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, "mongodb://resolved/", mc.ConnStr)
+	assert.Equal(t, "db-abc", mc.DB)
+
+	_, err = NewMongoConnToDB("")
+	assert.EqualError(t, err, "unknown environment")
+}
+
+func TestResolveConfigMissing(t *testing.T) {
+	t.Setenv("MONK_CONN_STR", "")
+	_, err := NewMongoConnToDB("abc")
+	assert.NotNil(t, err)
+}
@@ -1,5 +1,20 @@
 package monk
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
 type DocAction int
 
 const (
@@ -9,97 +24,428 @@ const (
 	DELETE
 )
 
-/*
-func Insert(m *mongo.Client, model interface{}, opt OptionalBehaviors, inputs ...interface{}) (interface{}, error) {
+// Insert validates the data against the model (defaults, trimming,
+// type conversion, timestamps, auto fields and verify tags) and writes
+// it as a new document into the model's collection. It returns the ID
+// of the inserted document, or FieldErrors if validation fails
+func Insert(mc *MongoConn, model interface{}, data do.Map) (interface{}, error) {
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	return insert(ctx, mc, model, data)
+}
+
+// insert is Insert, within the given context
+func insert(ctx context.Context, mc *MongoConn, model interface{}, data do.Map) (interface{}, error) {
 
 	// Validate inputs
 	if model == nil {
 		return nil, errors.New("model cannot be nil")
 	}
-
-	if len(inputs) == 0 {
+	if len(data) == 0 {
 		return nil, errors.New("no values provided to insert")
 	}
 
-	inputMap := NewMapFromVals(inputs)
-	InvokeHooks(INSERT, model, opt, inputMap)
-	RemoveUnwantedBehaviors(opt, inputMap)
+	if ok, issues := Validate(model, INSERT, data); !ok {
+		return nil, FieldErrors(issues)
+	}
+	for key, val := range insertMarks(model) {
+		data[key] = val
+	}
+
+	if err := InvokeHooks("BeforeInsert", model, data); err != nil {
+		return nil, err
+	}
+
+	doc := toDocument(model, data)
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, err
+	}
+	res, err := coll.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
 
-	// Do actual insert operations
+	doc["_id"] = res.InsertedID
+	if err := InvokeHooks("AfterInsert", model, do.Map(doc)); err != nil {
+		return res.InsertedID, err
+	}
 
-	return nil, nil
+	return res.InsertedID, nil
 }
 
-func InsertSelect(m *mongo.Client, inputs ...interface{}) (*bson.D, error) {
+// InsertSelect inserts just like Insert, and then reads back the
+// inserted document. The returned value is a pointer to the model type
+func InsertSelect(mc *MongoConn, model interface{}, data do.Map) (interface{}, error) {
 
-	//InvokeHooks(InsertDoc, nil)
+	id, err := Insert(mc, model, data)
+	if err != nil {
+		return nil, err
+	}
 
-	//RemoveUnwantedBehaviors(nil)
-	SetAutoKeysForInsert(nil)
+	ctx, cancel := GetContext()
+	defer cancel()
 
-	return nil, nil
+	// Hidden and secret fields are not read back
+	proj, err := newQueryConfig(nil).projection(model)
+	if err != nil {
+		return nil, err
+	}
+	findOpts := options.FindOne()
+	if len(proj) > 0 {
+		findOpts.SetProjection(proj)
+	}
+
+	out := newModel(model)
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, err
+	}
+	err = coll.FindOne(ctx, bson.M{"_id": id}, findOpts).Decode(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Update validates the changed fields against the model (update:"no" fields
+// are rejected, updated_at is refreshed) and applies them with $set to the
+// documents matching the filter. The filter cannot be nil: an empty filter
+// (eg. bson.M{}) has to be given to update every document. Nested structs
+// are set field by field using dotted paths, so sub-documents are not
+// replaced as a whole. It returns the number of documents matched.
+// AfterUpdate hooks receive the fields that were set, since more than
+// one document may have been updated.
+//
+// For Versioned models, data must hold the version the caller expects the
+// documents to be at; if none are at it, ErrVersionConflict is returned (but
+// if no documents match the filter at all, 0 is returned, as for others)
+func Update(mc *MongoConn, model interface{}, filter interface{}, data do.Map, opts ...QueryOption) (int64, error) {
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	return update(ctx, mc, model, filter, data, opts)
 }
 
-func Update(dbo interface{}, idKey string, idVal interface{}, inputs ...interface{}) error {
+// update is Update, within the given context
+func update(ctx context.Context, mc *MongoConn, model interface{}, filter interface{}, data do.Map, opts []QueryOption) (int64, error) {
 
-	//InvokeHooks(UpdateDocument, nil)
+	given := filter
+	filter, update, version, err := prepareUpdate(model, filter, data, opts)
+	if err != nil {
+		return 0, err
+	}
 
-	//RemoveUnwantedBehaviors(nil)
-	SetAutoKeysForUpdate(nil)
-	return nil
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return 0, err
+	}
+	res, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	if res.MatchedCount == 0 && isVersioned(model) {
+		if err = versionConflict(ctx, mc, model, given, version, opts); err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if err := InvokeHooks("AfterUpdate", model, do.Map(update["$set"].(bson.M))); err != nil {
+		return res.MatchedCount, err
+	}
+
+	return res.MatchedCount, nil
 }
 
-func UpdateSelect(dbo interface{}, idKey string, idVal interface{}, inputs ...interface{}) interface{} {
+// UpdateSelect updates a single document matching the filter just like
+// Update, and returns the document as it is after the update. The returned
+// value is a pointer to the model type. If no document matches the filter,
+// the error returned is mongo.ErrNoDocuments
+func UpdateSelect(mc *MongoConn, model interface{}, filter interface{}, data do.Map, opts ...QueryOption) (interface{}, error) {
 
-	//InvokeHooks(UpdateDocument, nil)
+	given := filter
+	filter, update, version, err := prepareUpdate(model, filter, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := GetContext()
+	defer cancel()
 
-	//RemoveUnwantedBehaviors(nil)
-	SetAutoKeysForUpdate(nil)
+	proj, err := newQueryConfig(opts).projection(model)
+	if err != nil {
+		return nil, err
+	}
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if len(proj) > 0 {
+		after.SetProjection(proj)
+	}
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, err
+	}
+	res := coll.FindOneAndUpdate(ctx, filter, update, after)
 
-	return nil
+	out := newModel(model)
+	if err = res.Decode(out); err != nil {
+		if err == mongo.ErrNoDocuments && isVersioned(model) {
+			return nil, versionConflict(ctx, mc, model, given, version, opts)
+		}
+		return nil, err
+	}
+
+	doc := do.Map{}
+	if err = res.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if err = InvokeHooks("AfterUpdate", model, doc); err != nil {
+		return out, err
+	}
+
+	return out, nil
 }
 
-func Delete(dbo interface{}, idKey string, idVal interface{}, inputs ...interface{}) error {
+// versionConflict is called when no document at the expected version
+// matches the filter. It returns ErrVersionConflict if documents match
+// the filter at other versions, or else mongo.ErrNoDocuments
+func versionConflict(ctx context.Context, mc *MongoConn, model interface{}, filter interface{}, version int, opts []QueryOption) error {
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return err
+	}
+	count, err := coll.CountDocuments(ctx, newQueryConfig(opts).scope(model, filter), options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return ErrVersionConflict{Expected: version}
+}
 
-	//InvokeHooks(DeleteDocument, nil)
+// prepareUpdate validates (a copy of) the data and returns the filter and
+// the update document to be sent to the database, along with the version
+// the documents are expected to be at (for Versioned models)
+func prepareUpdate(model interface{}, filter interface{}, data do.Map, opts []QueryOption) (interface{}, bson.M, int, error) {
 
-	//RemoveUnwantedBehaviors(nil)
-	SetAutoKeysForUpdate(nil)
-	return nil
+	// Validate inputs
+	if model == nil {
+		return nil, nil, 0, errors.New("model cannot be nil")
+	}
+	if len(data) == 0 {
+		return nil, nil, 0, errors.New("no values provided to update")
+	}
+	if isNilFilter(filter) {
+		return nil, nil, 0, errors.New("filter cannot be nil; pass an empty filter to update all documents")
+	}
+
+	// Validation and hooks change the data
+	data = copyData(data)
+
+	// The expected version is not a value to be set, rather
+	// it is a condition that the documents must meet
+	version := 0
+	if isVersioned(model) {
+		var err error
+		if version, err = expectedVersion(data); err != nil {
+			return nil, nil, 0, err
+		}
+		delete(data, "version")
+	}
+
+	if ok, issues := Validate(model, UPDATE, data); !ok {
+		return nil, nil, 0, FieldErrors(issues)
+	}
+
+	if err := InvokeHooks("BeforeUpdate", model, data); err != nil {
+		return nil, nil, 0, err
+	}
+
+	// Empty sub-documents set nothing
+	set := toSetDocument(model, data)
+	if len(set) == 0 {
+		return nil, nil, 0, errors.New("no values provided to update")
+	}
+
+	filter = newQueryConfig(opts).scope(model, filter)
+	update := bson.M{"$set": set}
+
+	if isVersioned(model) {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"version": version}}}
+		update["$inc"] = bson.M{"version": 1}
+	}
+
+	return filter, update, version, nil
+}
+
+// isNilFilter tells if no filter was given at all (rather than
+// an empty one), including nil maps such as bson.M(nil)
+func isNilFilter(filter interface{}) bool {
+	if filter == nil {
+		return true
+	}
+	rv := reflect.ValueOf(filter)
+	return (rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice || rv.Kind() == reflect.Ptr) && rv.IsNil()
 }
 
-func InvokeHooks(action DocAction, model interface{}, opt OptionalBehaviors, input Map) {
-	switch action {
-	case INSERT:
-		globalHook.Timed.BeforeInsert(input)
-	case UPDATE:
-	case DELETE:
+// Delete removes the documents matching the filter. Models composed
+// of Deletable are soft deleted instead: they are marked deleted=1 (with
+// deleted_at set), and are skipped by all other operations from then on.
+// The filter cannot be nil: an empty filter deletes all the documents.
+// It returns the number of documents deleted
+func Delete(mc *MongoConn, model interface{}, filter interface{}, opts ...QueryOption) (int64, error) {
+
+	if model == nil {
+		return 0, errors.New("model cannot be nil")
+	}
+	if isNilFilter(filter) {
+		return 0, errors.New("filter cannot be nil; pass an empty filter to delete all documents")
+	}
+
+	data := do.Map{}
+	if isDeletable(model) {
+		data = deleteMarks()
+	}
+	if err := InvokeHooks("BeforeDelete", model, data); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	scoped := newQueryConfig(opts).scope(model, filter)
+
+	if isDeletable(model) {
+		coll, err := mc.Collection(model)
+		if err != nil {
+			return 0, err
+		}
+		res, err := coll.UpdateMany(ctx, scoped, bson.M{"$set": toDocument(model, data)})
+		if err != nil {
+			return 0, err
+		}
+		return res.ModifiedCount, nil
+	}
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return 0, err
 	}
+	res, err := coll.DeleteMany(ctx, scoped)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
 }
 
-func RemoveUnwantedBehaviors(opt OptionalBehaviors, m Map) {
+// Find returns the documents matching the filter, as a
+// slice of the model type
+func Find(mc *MongoConn, model interface{}, filter interface{}, opts ...QueryOption) (interface{}, error) {
+
+	ctx, cancel := GetContext()
+	defer cancel()
 
-	// TODO:
-	// if a collection is not implementing some behaviours then
-	// should those be checked here?
+	qc := newQueryConfig(opts)
+	findOpts, err := qc.findOptions(model)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := coll.Find(ctx, qc.scope(model, filter), findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	list := newModelSlice(model)
+	if err = cur.All(ctx, list); err != nil {
+		return nil, err
+	}
+
+	return reflect.ValueOf(list).Elem().Interface(), nil
 }
 
-func SetAutoKeysForInsert(input map[string]interface{}) {
-	// created_at
+// FindOne returns the first document matching the filter, as
+// a pointer to the model type. If nothing matches, the error
+// returned is mongo.ErrNoDocuments
+func FindOne(mc *MongoConn, model interface{}, filter interface{}, opts ...QueryOption) (interface{}, error) {
+
+	ctx, cancel := GetContext()
+	defer cancel()
 
-	// updated_at
+	out := newModel(model)
+	qc := newQueryConfig(opts)
+	findOpts, err := qc.findOneOptions(model)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, err
+	}
+	err = coll.FindOne(ctx, qc.scope(model, filter), findOpts).Decode(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
 }
 
-func SetAutoKeysForUpdate(input map[string]interface{}) {
-	// updated_at
+// Count returns the number of documents matching the filter
+func Count(mc *MongoConn, model interface{}, filter interface{}, opts ...QueryOption) (int64, error) {
+
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return 0, err
+	}
+	return coll.CountDocuments(ctx, newQueryConfig(opts).scope(model, filter))
 }
 
-func PrepareData(inputs ...interface{}) (map[string]interface{}, error) {
-	return map[string]interface{}{}, errors.New("not implemented")
+// ErrVersionConflict is returned when updating Versioned documents,
+// if none of them are at the version the caller expected (ie. they
+// have been updated by someone else since the caller read them)
+type ErrVersionConflict struct {
+	Expected int
 }
 
-func isAddress(addr interface{}) bool {
-	return false
+func (e ErrVersionConflict) Error() string {
+	return fmt.Sprintf("version conflict: document is no longer at version %d", e.Expected)
 }
 
-*/
+// expectedVersion reads the version given by the
+// caller, which may have come in as a string
+func expectedVersion(data do.Map) (int, error) {
+	val, found := data["version"]
+	if !found {
+		return 0, errors.New("field 'version' needs a value upon updation")
+	}
+
+	switch v := val.(type) {
+	case int:
+		return v, nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case float64:
+		// As decoded from JSON
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("field 'version' expects 'int' but received %v", val)
+}
@@ -0,0 +1,237 @@
+package monk
+
+import (
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type crudTest struct {
+	ID    string `bson:"_id" auto:"prefix:ct-;uuid"`
+	Name  string `bson:"name" insert:"yes"`
+	Email string `bson:"email" verify:"email"`
+}
+
+func TestInsert(t *testing.T) {
+
+	// Validation failures are returned as FieldErrors
+	{
+		_, err := Insert(&testConnection, crudTest{}, do.Map{"email": "abc"})
+		errs, ok := err.(FieldErrors)
+		assert.True(t, ok)
+		assert.Contains(t, keys(errs), "name")
+		assert.Contains(t, keys(errs), "email")
+	}
+
+	// Valid data gets inserted and read back
+	{
+		out, err := InsertSelect(&testConnection, crudTest{}, do.Map{"name": " abc ", "email": "abc@def.com"})
+		require.NoError(t, err)
+		ct := out.(*crudTest)
+		assert.Equal(t, "abc", ct.Name)
+		assert.Regexp(t, `^ct-`, ct.ID)
+	}
+
+	// Secrets are not read back
+	{
+		out, err := InsertSelect(&testConnection, crudSecretTest{}, do.Map{"name": "abc", "password": "s3cret"})
+		require.NoError(t, err)
+		cs := out.(*crudSecretTest)
+		assert.Equal(t, "abc", cs.Name)
+		assert.Equal(t, "", cs.Password)
+	}
+}
+
+type crudSecretTest struct {
+	ID       string `bson:"_id" auto:"prefix:cs-;uuid"`
+	Name     string `bson:"name"`
+	Password string `bson:"password" secret:"bcrypt"`
+}
+
+type crudUpdateTest struct {
+	MongoStore `bson:",inline"`
+	ID         string  `bson:"_id" auto:"uuid"`
+	Code       string  `bson:"code" update:"no"`
+	Address    Address `bson:"address"`
+	Timed      `bson:",inline"`
+}
+
+func TestUpdate(t *testing.T) {
+
+	id, err := Insert(&testConnection, crudUpdateTest{}, do.Map{
+		"code":    "abc",
+		"address": map[string]interface{}{"city": "Pune", "street": "MG Road"},
+	})
+	assert.Nil(t, err)
+
+	// Fields tagged update:no cannot be changed
+	{
+		_, err := Update(&testConnection, crudUpdateTest{}, bson.M{"_id": id}, do.Map{"code": "def"})
+		assert.IsType(t, FieldErrors{}, err)
+	}
+
+	// Only the given sub-fields are changed
+	{
+		out, err := UpdateSelect(&testConnection, crudUpdateTest{}, bson.M{"_id": id}, do.Map{
+			"address": map[string]interface{}{"city": "Mumbai"},
+		})
+		require.NoError(t, err)
+		ct := out.(*crudUpdateTest)
+		assert.Equal(t, "Mumbai", ct.Address.City)
+		assert.Equal(t, "MG Road", ct.Address.Street)
+		assert.False(t, ct.UpdatedAt.Before(ct.CreatedAt))
+	}
+}
+
+type crudDeleteTest struct {
+	ID        string `bson:"_id" auto:"uuid"`
+	Name      string `bson:"name"`
+	Deletable `bson:",inline"`
+}
+
+// Overriding Deletable's hook still soft deletes
+func (crudDeleteTest) BeforeDelete(input do.Map) error {
+	return nil
+}
+
+func TestSoftDelete(t *testing.T) {
+
+	id, err := Insert(&testConnection, crudDeleteTest{}, do.Map{"name": "abc"})
+	assert.Nil(t, err)
+
+	count, err := Delete(&testConnection, crudDeleteTest{}, bson.M{"_id": id})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Document is hidden from queries
+	count, _ = Count(&testConnection, crudDeleteTest{}, bson.M{"_id": id})
+	assert.Equal(t, int64(0), count)
+
+	// But it is still there
+	out, err := FindOne(&testConnection, crudDeleteTest{}, bson.M{"_id": id}, IncludeDeleted)
+	require.NoError(t, err)
+	ct := out.(*crudDeleteTest)
+	assert.Equal(t, uint(1), ct.Deleted)
+	assert.NotNil(t, ct.DeletedAt)
+}
+
+func TestPrepareUpdateFilter(t *testing.T) {
+
+	// Updating everything needs an explicit empty filter
+	_, _, _, err := prepareUpdate(crudTest{}, nil, do.Map{"name": "abc"}, nil)
+	assert.NotNil(t, err)
+	_, _, _, err = prepareUpdate(crudTest{}, bson.M(nil), do.Map{"name": "abc"}, nil)
+	assert.NotNil(t, err)
+
+	filter, update, _, err := prepareUpdate(crudTest{}, bson.M{}, do.Map{"name": "abc"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, bson.M{}, filter)
+	assert.Equal(t, bson.M{"name": "abc"}, update["$set"])
+}
+
+type crudNestedTest struct {
+	ID      string  `bson:"_id"`
+	Address Address `bson:"address"`
+}
+
+func TestPrepareUpdateEmpty(t *testing.T) {
+
+	// Empty sub-documents leave nothing to set
+	_, _, _, err := prepareUpdate(crudNestedTest{}, bson.M{}, do.Map{"address": map[string]interface{}{}}, nil)
+	assert.EqualError(t, err, "no values provided to update")
+
+	_, update, _, err := prepareUpdate(crudNestedTest{}, bson.M{}, do.Map{"address": map[string]interface{}{"city": "pune"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, bson.M{"address.city": "pune"}, update["$set"])
+}
+
+func TestDeleteFilter(t *testing.T) {
+
+	// Deleting everything needs an explicit empty filter
+	_, err := Delete(&testConnection, crudDeleteTest{}, nil)
+	assert.NotNil(t, err)
+	_, err = Delete(&testConnection, crudDeleteTest{}, bson.M(nil))
+	assert.NotNil(t, err)
+}
+
+func TestExpectedVersion(t *testing.T) {
+
+	v, err := expectedVersion(do.Map{"version": 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, v)
+
+	v, err = expectedVersion(do.Map{"version": " 4 "})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, v)
+
+	_, err = expectedVersion(do.Map{})
+	assert.NotNil(t, err)
+
+	_, err = expectedVersion(do.Map{"version": "abc"})
+	assert.NotNil(t, err)
+
+	// Numbers decoded from JSON are floats, which must be whole
+	v, err = expectedVersion(do.Map{"version": 5.0})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, v)
+
+	_, err = expectedVersion(do.Map{"version": 2.7})
+	assert.NotNil(t, err)
+}
+
+func TestPrepareUpdateCopies(t *testing.T) {
+
+	// The caller's data is left as it was
+	data := do.Map{"name": "v2", "version": 3}
+	filter, update, version, err := prepareUpdate(crudVersionTest{}, bson.M{"_id": "x"}, data, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, do.Map{"name": "v2", "version": 3}, data)
+	assert.Equal(t, 3, version)
+	assert.Equal(t, bson.M{"$and": bson.A{bson.M{"_id": "x"}, bson.M{"version": 3}}}, filter)
+	assert.Equal(t, bson.M{"version": 1}, update["$inc"])
+
+	// Sub-documents too
+	address := map[string]interface{}{"city": " pune "}
+	_, _, _, err = prepareUpdate(crudUpdateTest{}, bson.M{}, do.Map{"address": address}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"city": " pune "}, address)
+}
+
+type crudVersionTest struct {
+	ID        string `bson:"_id" auto:"uuid"`
+	Name      string `bson:"name"`
+	Versioned `bson:",inline"`
+}
+
+// Overriding Versioned's hook still starts at version 1
+func (crudVersionTest) BeforeInsert(input do.Map) error {
+	return nil
+}
+
+func TestVersionConflict(t *testing.T) {
+
+	out, err := InsertSelect(&testConnection, crudVersionTest{}, do.Map{"name": "v1"})
+	require.NoError(t, err)
+	ct := out.(*crudVersionTest)
+	assert.Equal(t, 1, ct.Version)
+
+	// Update at the expected version bumps it
+	out, err = UpdateSelect(&testConnection, crudVersionTest{}, bson.M{"_id": ct.ID}, do.Map{"name": "v2", "version": 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, out.(*crudVersionTest).Version)
+
+	// A stale version is rejected
+	_, err = Update(&testConnection, crudVersionTest{}, bson.M{"_id": ct.ID}, do.Map{"name": "v3", "version": 1})
+	assert.Equal(t, ErrVersionConflict{Expected: 1}, err)
+
+	// A missing document is not a conflict
+	count, err := Update(&testConnection, crudVersionTest{}, bson.M{"_id": "missing"}, do.Map{"name": "v3", "version": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+	_, err = UpdateSelect(&testConnection, crudVersionTest{}, bson.M{"_id": "missing"}, do.Map{"name": "v3", "version": 1})
+	assert.Equal(t, mongo.ErrNoDocuments, err)
+}
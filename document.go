@@ -0,0 +1,171 @@
+package monk
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BsonKey returns the key under which the mongo driver stores
+// the given struct field: the name in the bson tag, or else the
+// lowercased field name
+func BsonKey(sf reflect.StructField) string {
+	name := strings.Split(sf.Tag.Get("bson"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+// isNestedStruct tells if the field holds a sub-document
+// (struct or pointer to struct, except time.Time)
+func isNestedStruct(sf reflect.StructField) bool {
+	ft := do.TypeDereference(sf.Type)
+	return ft.Kind() == reflect.Struct && !do.TypeIsTime(ft)
+}
+
+// asMap returns the input as a go map, if it is one
+func asMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case do.Map:
+		return map[string]interface{}(m), true
+	case bson.M:
+		return map[string]interface{}(m), true
+	case bson.D:
+		return map[string]interface{}(m.Map()), true
+	}
+	return nil, false
+}
+
+// copyData returns a copy of the data, in which nested maps are
+// copied as well, so that it can be changed without the caller's
+func copyData(data do.Map) do.Map {
+	out := do.Map{}
+	for k, v := range data {
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v = map[string]interface{}(copyData(m))
+		case do.Map:
+			v = copyData(m)
+		case bson.M:
+			v = bson.M(copyData(do.Map(m)))
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// toDocument converts the data (keyed as Validate keys it) into
+// a bson document keyed as per the model's bson keys. Embedded
+// structs are inlined. Keys that do not map to any field are
+// carried over as they are
+func toDocument(modelType interface{}, data map[string]interface{}) bson.M {
+	doc := bson.M{}
+	for k, v := range data {
+		doc[k] = v
+	}
+
+	walkFields(modelType, func(sf reflect.StructField) {
+		key := FieldKey(sf)
+		val, found := data[key]
+		if !found {
+			return
+		}
+		delete(doc, key)
+		if inner, isMap := asMap(val); isMap && isNestedStruct(sf) {
+			val = toDocument(sf.Type, inner)
+		}
+		doc[BsonKey(sf)] = val
+	})
+
+	return doc
+}
+
+// fromDocument is the reverse of toDocument: it keys the
+// document's values the way Validate keys them
+func fromDocument(modelType interface{}, doc map[string]interface{}) do.Map {
+	data := do.Map{}
+	for k, v := range doc {
+		data[k] = v
+	}
+
+	walkFields(modelType, func(sf reflect.StructField) {
+		key := BsonKey(sf)
+		val, found := doc[key]
+		if !found {
+			return
+		}
+		delete(data, key)
+		if inner, isMap := asMap(val); isMap && isNestedStruct(sf) {
+			val = map[string]interface{}(fromDocument(sf.Type, inner))
+		}
+		data[FieldKey(sf)] = val
+	})
+
+	return data
+}
+
+// toSetDocument converts the data into a document fit for $set, in which
+// nested struct values are flattened into dotted paths, so that only the
+// given sub-fields are overwritten rather than the entire sub-document
+func toSetDocument(modelType interface{}, data map[string]interface{}) bson.M {
+	out := bson.M{}
+	flattenInto(modelType, toDocument(modelType, data), "", out)
+	return out
+}
+
+func flattenInto(modelType interface{}, doc bson.M, prefix string, out bson.M) {
+	nested := map[string]reflect.StructField{}
+	walkFields(modelType, func(sf reflect.StructField) {
+		if isNestedStruct(sf) {
+			nested[BsonKey(sf)] = sf
+		}
+	})
+
+	for k, v := range doc {
+		sf, isNested := nested[k]
+		inner, isMap := asMap(v)
+		if isNested && isMap {
+			// Empty sub-documents set nothing, rather
+			// than wiping out the stored sub-document
+			flattenInto(sf.Type, bson.M(inner), prefix+k+".", out)
+		} else {
+			out[prefix+k] = v
+		}
+	}
+}
+
+// walkFields invokes op for every field of the model, descending
+// into embedded structs as if their fields belonged to the model
+func walkFields(modelType interface{}, op func(reflect.StructField)) {
+	ot := do.TypeDereference(do.TypeOf(modelType))
+	if ot.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < ot.NumField(); i++ {
+		sf := ot.Field(i)
+		if sf.Anonymous && isNestedStruct(sf) {
+			walkFields(sf.Type, op)
+			continue
+		}
+		op(sf)
+	}
+}
+
+// newModel returns a pointer to a new zero value of the model's type
+func newModel(modelType interface{}) interface{} {
+	ot := do.TypeDereference(do.TypeOf(modelType))
+	return reflect.New(ot).Interface()
+}
+
+// newModelSlice returns a pointer to a new empty slice of the model's type
+func newModelSlice(modelType interface{}) interface{} {
+	ot := do.TypeDereference(do.TypeOf(modelType))
+	list := reflect.New(reflect.SliceOf(ot))
+	list.Elem().Set(reflect.MakeSlice(reflect.SliceOf(ot), 0, 0))
+	return list.Interface()
+}
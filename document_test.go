@@ -0,0 +1,63 @@
+package monk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type docTest struct {
+	UUID    string `bson:"_id"`
+	Name    string `bson:"name"`
+	Address Address
+	Timed   `bson:",inline"`
+}
+
+func TestToDocument(t *testing.T) {
+
+	doc := toDocument(docTest{}, map[string]interface{}{
+		"uuid":       "u-1",
+		"name":       "abc",
+		"created_at": "now",
+		"address":    map[string]interface{}{"city": "Pune", "postal_code": "411001"},
+		"extra":      1,
+	})
+
+	assert.Equal(t, "u-1", doc["_id"])
+	assert.Equal(t, "abc", doc["name"])
+	assert.Equal(t, "now", doc["created_at"])
+	assert.Equal(t, 1, doc["extra"])
+	assert.Equal(t, bson.M{"city": "Pune", "postalcode": "411001"}, doc["address"])
+	_, found := doc["uuid"]
+	assert.False(t, found)
+}
+
+func TestToSetDocument(t *testing.T) {
+
+	doc := toSetDocument(docTest{}, map[string]interface{}{
+		"name":    "abc",
+		"address": map[string]interface{}{"city": "Pune"},
+	})
+
+	assert.Equal(t, bson.M{"name": "abc", "address.city": "Pune"}, doc)
+
+	doc = toSetDocument(docTest{}, map[string]interface{}{
+		"name":    "abc",
+		"address": map[string]interface{}{},
+	})
+	assert.Equal(t, bson.M{"name": "abc"}, doc)
+}
+
+func TestFromDocument(t *testing.T) {
+
+	data := fromDocument(docTest{}, map[string]interface{}{
+		"_id":        "u-1",
+		"created_at": "now",
+		"address":    bson.M{"postalcode": "411001"},
+	})
+
+	assert.Equal(t, "u-1", data["uuid"])
+	assert.Equal(t, "now", data["created_at"])
+	assert.Equal(t, map[string]interface{}{"postal_code": "411001"}, data["address"])
+}
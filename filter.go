@@ -0,0 +1,256 @@
+package monk
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/outerjoin/do"
+	"github.com/rightjoin/rutl/conv"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Filter builds a mongo filter for a model. Fields are given by their
+// json paths (eg. "address.city"), which are checked against the model
+// and translated to the paths the documents are stored with. String
+// values are converted to the field's type, as Validate converts them.
+// Issues are collected, and returned as FieldErrors by D
+type Filter struct {
+	model interface{}
+	doc   bson.D
+	errs  FieldErrors
+}
+
+func NewFilter(model interface{}) *Filter {
+	return &Filter{model: model, doc: bson.D{}, errs: FieldErrors{}}
+}
+
+func (f *Filter) Eq(path string, value interface{}) *Filter {
+	return f.compare(path, "$eq", value)
+}
+
+func (f *Filter) Ne(path string, value interface{}) *Filter {
+	return f.compare(path, "$ne", value)
+}
+
+func (f *Filter) Gt(path string, value interface{}) *Filter {
+	return f.compare(path, "$gt", value)
+}
+
+func (f *Filter) Gte(path string, value interface{}) *Filter {
+	return f.compare(path, "$gte", value)
+}
+
+func (f *Filter) Lt(path string, value interface{}) *Filter {
+	return f.compare(path, "$lt", value)
+}
+
+func (f *Filter) Lte(path string, value interface{}) *Filter {
+	return f.compare(path, "$lte", value)
+}
+
+func (f *Filter) In(path string, values ...interface{}) *Filter {
+	return f.compare(path, "$in", values...)
+}
+
+func (f *Filter) Nin(path string, values ...interface{}) *Filter {
+	return f.compare(path, "$nin", values...)
+}
+
+// Regex matches string fields against the pattern. Options
+// are those of mongo's $regex (eg. "i" for case insensitive)
+func (f *Filter) Regex(path string, pattern string, options string) *Filter {
+	t, found := StructGetFieldTypeByJsonKey(f.model, path)
+	if !found {
+		f.errs.Add("unknown field", path)
+		return f
+	}
+	if elemType(t).Kind() != reflect.String {
+		f.errs.Add("field is not a string", path)
+		return f
+	}
+
+	bsonPath, _, _ := resolveField(do.TypeOf(f.model), strings.Split(path, "."))
+	f.add(bsonPath, "$regex", primitive.Regex{Pattern: pattern, Options: options})
+	return f
+}
+
+// Exists matches documents that have (or do not have) the field.
+// Unlike comparisons, it also works on sub-documents and arrays
+func (f *Filter) Exists(path string, exists bool) *Filter {
+	bsonPath, _, found := resolveField(do.TypeOf(f.model), strings.Split(path, "."))
+	if !found {
+		f.errs.Add("unknown field", path)
+		return f
+	}
+
+	f.add(bsonPath, "$exists", exists)
+	return f
+}
+
+// ElemMatch matches documents having an element in the array (of
+// sub-documents) that matches all the conditions given by build.
+// Paths within build are relative to the elements
+func (f *Filter) ElemMatch(path string, build func(elem *Filter)) *Filter {
+	bsonPath, sf, found := resolveField(do.TypeOf(f.model), strings.Split(path, "."))
+	if !found {
+		f.errs.Add("unknown field", path)
+		return f
+	}
+	elem := do.TypeDereference(elemType(sf.Type))
+	if do.TypeDereference(sf.Type).Kind() != reflect.Slice || elem.Kind() != reflect.Struct {
+		f.errs.Add("field is not an array of sub-documents", path)
+		return f
+	}
+
+	sub := NewFilter(elem)
+	build(sub)
+	for key, issues := range sub.errs {
+		for _, issue := range issues {
+			f.errs.Add(issue, path, key)
+		}
+	}
+
+	f.add(bsonPath, "$elemMatch", sub.doc)
+	return f
+}
+
+// And matches documents that match all the filters
+func (f *Filter) And(filters ...*Filter) *Filter {
+	return f.combine("$and", filters)
+}
+
+// Or matches documents that match any of the filters
+func (f *Filter) Or(filters ...*Filter) *Filter {
+	return f.combine("$or", filters)
+}
+
+// D returns the filter, or FieldErrors if any of the
+// paths or values given to the filter are invalid
+func (f *Filter) D() (bson.D, error) {
+	if len(f.errs) > 0 {
+		return nil, f.errs
+	}
+	return f.doc, nil
+}
+
+func (f *Filter) combine(op string, filters []*Filter) *Filter {
+	list := bson.A{}
+	for _, sub := range filters {
+		for key, issues := range sub.errs {
+			for _, issue := range issues {
+				f.errs.Add(issue, key)
+			}
+		}
+		list = append(list, sub.doc)
+	}
+
+	f.doc = append(f.doc, bson.E{Key: op, Value: list})
+	return f
+}
+
+// compare adds a comparison of the field with the value(s), which
+// must be of the field's type, or strings that convert to it
+func (f *Filter) compare(path string, op string, values ...interface{}) *Filter {
+	t, found := StructGetFieldTypeByJsonKey(f.model, path)
+	if !found {
+		f.errs.Add("unknown field", path)
+		return f
+	}
+
+	converted := bson.A{}
+	for _, val := range values {
+		if str, isStr := val.(string); isStr {
+			v, err := coerce(elemType(t), str)
+			if err != nil {
+				f.errs.Add(err.Error(), path)
+				return f
+			}
+			val = v
+		}
+		converted = append(converted, val)
+	}
+
+	bsonPath, _, _ := resolveField(do.TypeOf(f.model), strings.Split(path, "."))
+	if op == "$in" || op == "$nin" {
+		f.add(bsonPath, op, converted)
+	} else {
+		f.add(bsonPath, op, converted[0])
+	}
+	return f
+}
+
+// add puts the condition on the field together with the
+// other conditions on the same field, if there are any
+func (f *Filter) add(bsonPath string, op string, value interface{}) {
+	for i, e := range f.doc {
+		if e.Key != bsonPath {
+			continue
+		}
+		if conds, ok := e.Value.(bson.D); ok {
+			f.doc[i].Value = append(conds, bson.E{Key: op, Value: value})
+			return
+		}
+	}
+	f.doc = append(f.doc, bson.E{Key: bsonPath, Value: bson.D{{Key: op, Value: value}}})
+}
+
+// elemType returns the type of the elements of arrays, as
+// fields holding arrays are compared to single elements
+func elemType(t reflect.Type) reflect.Type {
+	t = do.TypeDereference(t)
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		return t.Elem()
+	}
+	return t
+}
+
+// resolveField finds the field at the json path (as understood by
+// StructGetFieldTypeByJsonKey), and translates the path into the one the
+// field is stored under. Embedded structs and untagged sub-structs are
+// looked into, and paths may go through arrays of sub-documents
+func resolveField(t reflect.Type, path []string) (string, reflect.StructField, bool) {
+	t = do.TypeDereference(t)
+	if t.Kind() == reflect.Slice {
+		t = do.TypeDereference(t.Elem())
+	}
+	if t.Kind() != reflect.Struct || do.TypeIsTime(t) {
+		return "", reflect.StructField{}, false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		key := sf.Tag.Get("json")
+		if key == "" && isNestedStruct(sf) {
+			rest, leaf, found := resolveField(sf.Type, path)
+			if !found {
+				continue
+			}
+			if sf.Anonymous {
+				return rest, leaf, true
+			}
+			return BsonKey(sf) + "." + rest, leaf, true
+		}
+		if key == "" {
+			key = conv.CaseSnake(sf.Name)
+		}
+		if key != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return BsonKey(sf), sf, true
+		}
+		rest, leaf, found := resolveField(sf.Type, path[1:])
+		if !found {
+			break
+		}
+		return BsonKey(sf) + "." + rest, leaf, true
+	}
+
+	return "", reflect.StructField{}, false
+}
@@ -0,0 +1,100 @@
+package monk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type filterTest struct {
+	Timed `bson:",inline"`
+	ID    string  `bson:"_id" json:"uuid"`
+	Name  string  `bson:"name" json:"name"`
+	Age   int     `bson:"age" json:"age"`
+	Score float64 `bson:"score"`
+	Tags  []string
+	Home  struct {
+		City string `bson:"city" json:"city"`
+	} `bson:"home" json:"home"`
+	Orders []struct {
+		Qty  int    `bson:"qty" json:"qty"`
+		Item string `bson:"sku" json:"item"`
+	} `bson:"orders" json:"orders"`
+}
+
+func TestFilter(t *testing.T) {
+
+	d, err := NewFilter(filterTest{}).
+		Eq("name", "joe").
+		Gte("age", "18").
+		Lt("age", 65).
+		In("tags", "a", "b").
+		Eq("home.city", "Pune").
+		Regex("name", "^j", "i").
+		Exists("home", true).
+		D()
+
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "name", Value: bson.D{{Key: "$eq", Value: "joe"}, {Key: "$regex", Value: primitive.Regex{Pattern: "^j", Options: "i"}}}},
+		{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}, {Key: "$lt", Value: 65}}},
+		{Key: "tags", Value: bson.D{{Key: "$in", Value: bson.A{"a", "b"}}}},
+		{Key: "home.city", Value: bson.D{{Key: "$eq", Value: "Pune"}}},
+		{Key: "home", Value: bson.D{{Key: "$exists", Value: true}}},
+	}, d)
+
+	// Paths are translated to bson keys, and embedded structs are inlined
+	d, err = NewFilter(filterTest{}).Gt("created_at", "2024-01-02").Eq("uuid", "u-1").D()
+	assert.Nil(t, err)
+	assert.Equal(t, "created_at", d[0].Key)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), d[0].Value.(bson.D)[0].Value)
+	assert.Equal(t, "_id", d[1].Key)
+}
+
+func TestFilterCombined(t *testing.T) {
+
+	d, err := NewFilter(filterTest{}).
+		Or(
+			NewFilter(filterTest{}).Eq("score", "1.5"),
+			NewFilter(filterTest{}).Eq("orders.item", "pen"),
+		).
+		ElemMatch("orders", func(elem *Filter) {
+			elem.Eq("item", "pen").Gt("qty", "2")
+		}).
+		D()
+
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "score", Value: bson.D{{Key: "$eq", Value: 1.5}}}},
+			bson.D{{Key: "orders.sku", Value: bson.D{{Key: "$eq", Value: "pen"}}}},
+		}},
+		{Key: "orders", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "sku", Value: bson.D{{Key: "$eq", Value: "pen"}}},
+			{Key: "qty", Value: bson.D{{Key: "$gt", Value: 2}}},
+		}}}},
+	}, d)
+}
+
+func TestFilterErrors(t *testing.T) {
+
+	_, err := NewFilter(filterTest{}).
+		Eq("nmae", "joe").
+		Gt("age", "old").
+		Regex("age", "1", "").
+		ElemMatch("home", func(elem *Filter) {}).
+		And(NewFilter(filterTest{}).Exists("missing", true)).
+		ElemMatch("orders", func(elem *Filter) { elem.Eq("qtty", 1) }).
+		D()
+
+	errs, ok := err.(FieldErrors)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"unknown field"}, errs["nmae"])
+	assert.Equal(t, 2, len(errs["age"]))
+	assert.Equal(t, []string{"field is not an array of sub-documents"}, errs["home"])
+	assert.Equal(t, []string{"unknown field"}, errs["missing"])
+	assert.Equal(t, []string{"unknown field"}, errs["orders.qtty"])
+}
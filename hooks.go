@@ -0,0 +1,125 @@
+package monk
+
+import (
+	"reflect"
+
+	"github.com/outerjoin/do"
+)
+
+// Models (and the mixins embedded in them) can hook into the
+// write operations by implementing any of the following. An
+// error returned by a hook aborts the operation.
+//
+// Before* hooks receive the validated input, which they may modify
+// before it is written. After* hooks receive the persisted document
+//
+// As with any method in Go, a hook the model has (its own, or promoted
+// from a mixin) overrides the hooks of its mixins; call them from the
+// model's hook to keep them. The fields kept by the package's mixins
+// (version, deleted, ...) are filled in by the operations themselves,
+// and so are not lost by overriding their hooks
+
+type BeforeInsertHook interface {
+	BeforeInsert(input do.Map) error
+}
+
+type AfterInsertHook interface {
+	AfterInsert(doc do.Map) error
+}
+
+type BeforeUpdateHook interface {
+	BeforeUpdate(input do.Map) error
+}
+
+// AfterUpdate receives the document as updated from UpdateSelect. As
+// Update may update many documents, from it AfterUpdate receives the
+// fields that were set instead (by their bson paths, eg. "address.city")
+type AfterUpdateHook interface {
+	AfterUpdate(doc do.Map) error
+}
+
+type BeforeDeleteHook interface {
+	BeforeDelete(input do.Map) error
+}
+
+// InvokeHooks calls the named hook (BeforeInsert, AfterUpdate, ...) on
+// the model. If the model has no such hook, as its mixins' collide, it
+// is called on every one of them, in embedding order. It stops at the
+// first error
+func InvokeHooks(hook string, model interface{}, data do.Map) error {
+
+	for _, target := range hookTargets(model, hook) {
+		var err error
+		switch hook {
+		case "BeforeInsert":
+			if h, ok := target.(BeforeInsertHook); ok {
+				err = h.BeforeInsert(data)
+			}
+		case "AfterInsert":
+			if h, ok := target.(AfterInsertHook); ok {
+				err = h.AfterInsert(data)
+			}
+		case "BeforeUpdate":
+			if h, ok := target.(BeforeUpdateHook); ok {
+				err = h.BeforeUpdate(data)
+			}
+		case "AfterUpdate":
+			if h, ok := target.(AfterUpdateHook); ok {
+				err = h.AfterUpdate(data)
+			}
+		case "BeforeDelete":
+			if h, ok := target.(BeforeDeleteHook); ok {
+				err = h.BeforeDelete(data)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hookTargets returns (addresses of) the model, or else of the embedded
+// mixins, that have the given method, in the order they must be called
+func hookTargets(model interface{}, method string) []interface{} {
+
+	ot := do.TypeDereference(do.TypeOf(model))
+	if ot.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// Work on an addressable copy of the model, so that
+	// hooks see the values (if any) of the given model
+	ov := reflect.New(ot)
+	if mv := reflect.ValueOf(model); mv.IsValid() {
+		if mv.Kind() == reflect.Ptr && !mv.IsNil() {
+			mv = mv.Elem()
+		}
+		if mv.Type() == ot {
+			ov.Elem().Set(mv)
+		}
+	}
+
+	return appendHookTargets(nil, ov, method)
+}
+
+func appendHookTargets(list []interface{}, ptr reflect.Value, method string) []interface{} {
+	st := ptr.Elem().Type()
+
+	// The type's method, whether declared or promoted,
+	// is the one Go would call, so it is called alone
+	if _, hasMethod := reflect.PtrTo(st).MethodByName(method); hasMethod {
+		return append(list, ptr.Interface())
+	}
+
+	// The mixins' methods collide (or there are none)
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.IsExported() {
+			list = appendHookTargets(list, ptr.Elem().Field(i).Addr(), method)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,134 @@
+package monk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+)
+
+type HookMixinA struct{}
+
+func (HookMixinA) BeforeInsert(input do.Map) error {
+	input["order"] = append(input["order"].([]string), "a")
+	return nil
+}
+
+type HookMixinB struct{}
+
+func (*HookMixinB) BeforeInsert(input do.Map) error {
+	input["order"] = append(input["order"].([]string), "b")
+	return nil
+}
+
+type hookPromoted struct {
+	HookMixinA
+}
+
+type hookMixins struct {
+	Name string
+	HookMixinA
+	HookMixinB
+}
+
+type hookModel struct {
+	Name string
+	HookMixinA
+	HookMixinB
+}
+
+func (h *hookModel) BeforeInsert(input do.Map) error {
+	h.HookMixinA.BeforeInsert(input)
+	input["order"] = append(input["order"].([]string), "model")
+	return nil
+}
+
+type hookFails struct {
+	Deletable
+}
+
+func (hookFails) BeforeDelete(input do.Map) error {
+	return errors.New("cannot delete")
+}
+
+// hookOverrides overrides the hooks of Deletable and Versioned
+type hookOverrides struct {
+	Deletable
+	Versioned
+}
+
+func (hookOverrides) BeforeInsert(input do.Map) error {
+	input["order"] = append(input["order"].([]string), "model")
+	return nil
+}
+
+func (hookOverrides) BeforeDelete(input do.Map) error {
+	input["order"] = append(input["order"].([]string), "model")
+	return nil
+}
+
+func TestInvokeHooks(t *testing.T) {
+
+	// Colliding mixins are called in embedding order
+	{
+		m := do.Map{"order": []string{}}
+		err := InvokeHooks("BeforeInsert", hookMixins{}, m)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b"}, m["order"])
+	}
+
+	// The model's hook overrides its mixins', which it may call
+	{
+		m := do.Map{"order": []string{}}
+		err := InvokeHooks("BeforeInsert", hookModel{}, m)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "model"}, m["order"])
+
+		// Pointers to models work too
+		m = do.Map{"order": []string{}}
+		InvokeHooks("BeforeInsert", &hookModel{}, m)
+		assert.Equal(t, []string{"a", "model"}, m["order"])
+	}
+
+	// A promoted method is called only once
+	{
+		m := do.Map{"order": []string{}}
+		InvokeHooks("BeforeInsert", hookPromoted{}, m)
+		assert.Equal(t, []string{"a"}, m["order"])
+
+		// However deep it is promoted from
+		m = do.Map{"order": []string{}}
+		InvokeHooks("BeforeInsert", struct{ hookPromoted }{}, m)
+		assert.Equal(t, []string{"a"}, m["order"])
+	}
+
+	// Errors are returned
+	{
+		err := InvokeHooks("BeforeDelete", hookFails{}, do.Map{})
+		assert.EqualError(t, err, "cannot delete")
+	}
+}
+
+func TestOverriddenMixinHooks(t *testing.T) {
+
+	// Overriding the hooks of Deletable and Versioned
+	// does not lose the fields they keep
+	marks := insertMarks(hookOverrides{})
+	assert.Equal(t, do.Map{"version": 1, "deleted": 0}, marks)
+
+	marks["order"] = []string{}
+	assert.Nil(t, InvokeHooks("BeforeInsert", hookOverrides{}, marks))
+	assert.Equal(t, []string{"model"}, marks["order"])
+	assert.Equal(t, 1, marks["version"])
+
+	marks = deleteMarks()
+	marks["order"] = []string{}
+	assert.Nil(t, InvokeHooks("BeforeDelete", hookOverrides{}, marks))
+	assert.Equal(t, []string{"model"}, marks["order"])
+	assert.Equal(t, 1, marks["deleted"])
+	assert.NotNil(t, marks["deleted_at"])
+
+	// Nor do models without them
+	assert.Equal(t, do.Map{}, insertMarks(hookModel{}))
+}
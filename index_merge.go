@@ -0,0 +1,187 @@
+package monk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IndexError describes an issue with one of the indexes of a model
+type IndexError struct {
+	Index string
+	Err   error
+}
+
+func (e IndexError) Error() string {
+	return fmt.Sprintf("index %s: %s", e.Index, e.Err)
+}
+
+func (e IndexError) Unwrap() error {
+	return e.Err
+}
+
+type IndexErrors []IndexError
+
+func (errs IndexErrors) Error() string {
+	list := []string{}
+	for _, e := range errs {
+		list = append(list, e.Error())
+	}
+	return strings.Join(list, "; ")
+}
+
+// has tells if any of the errors concern the named index
+func (errs IndexErrors) has(name string) bool {
+	for _, e := range errs {
+		if e.Index == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeIndexes merges the declarations that share a name into one
+// index, keeping indexes in the order they were first declared
+func mergeIndexes(decls []MonkIndex) ([]MonkIndex, error) {
+	names := []string{}
+	byName := map[string][]MonkIndex{}
+	for _, decl := range decls {
+		if _, found := byName[decl.Name]; !found {
+			names = append(names, decl.Name)
+		}
+		byName[decl.Name] = append(byName[decl.Name], decl)
+	}
+
+	list := []MonkIndex{}
+	errs := IndexErrors{}
+	for _, name := range names {
+		idx, err := mergeIndexGroup(byName[name])
+		if err != nil {
+			errs = append(errs, IndexError{Index: name, Err: err})
+			continue
+		}
+		list = append(list, idx)
+	}
+
+	if len(errs) > 0 {
+		return list, errs
+	}
+	return list, nil
+}
+
+type indexField struct {
+	name     string
+	order    int
+	kind     string
+	position int
+}
+
+func mergeIndexGroup(group []MonkIndex) (MonkIndex, error) {
+	if len(group) == 1 {
+		return group[0], nil
+	}
+
+	merged := group[0]
+	merged.Position = 0
+	merged.Weights = nil
+
+	fields := []indexField{}
+	seen := map[string]indexField{}
+	for _, decl := range group {
+		if decl.Unique != merged.Unique {
+			return merged, errors.New("declared both as unique and not unique")
+		}
+		if err := mergeIndexOptions(&merged, decl); err != nil {
+			return merged, err
+		}
+
+		for i, name := range decl.Fields {
+			f := indexField{name: name, order: decl.Order[i], position: decl.Position}
+			if i < len(decl.Types) {
+				f.kind = decl.Types[i]
+			}
+			if prev, found := seen[name]; found {
+				// The same field may be repeated (eg. when a compound index
+				// is declared in full on more than one field) but only alike
+				if prev.order != f.order || prev.kind != f.kind {
+					return merged, fmt.Errorf("field %s declared with different orders", name)
+				}
+				continue
+			}
+			seen[name] = f
+			fields = append(fields, f)
+		}
+	}
+
+	ordered, err := orderIndexFields(fields)
+	if err != nil {
+		return merged, err
+	}
+
+	merged.Fields, merged.Order, merged.Types = []string{}, []int{}, []string{}
+	for _, f := range ordered {
+		merged.Fields = append(merged.Fields, f.name)
+		merged.Order = append(merged.Order, f.order)
+		merged.Types = append(merged.Types, f.kind)
+	}
+	return merged, nil
+}
+
+// orderIndexFields places the fields that have an explicit (1 based)
+// position at it, and the remaining ones in order of declaration
+func orderIndexFields(fields []indexField) ([]indexField, error) {
+	slots := make([]*indexField, len(fields))
+	for i := range fields {
+		pos := fields[i].position
+		if pos == 0 {
+			continue
+		}
+		if pos < 0 || pos > len(fields) {
+			return nil, fmt.Errorf("position %d of field %s is out of range", pos, fields[i].name)
+		}
+		if slots[pos-1] != nil {
+			return nil, fmt.Errorf("fields %s and %s declared at the same position %d", slots[pos-1].name, fields[i].name, pos)
+		}
+		slots[pos-1] = &fields[i]
+	}
+
+	next := 0
+	for i := range fields {
+		if fields[i].position != 0 {
+			continue
+		}
+		for slots[next] != nil {
+			next++
+		}
+		slots[next] = &fields[i]
+	}
+
+	ordered := []indexField{}
+	for _, f := range slots {
+		ordered = append(ordered, *f)
+	}
+	return ordered, nil
+}
+
+// mergeIndexOptions takes over the options set on the declaration,
+// which must not contradict those declared already
+func mergeIndexOptions(merged *MonkIndex, decl MonkIndex) error {
+	if indexOptionsSignature(decl) != "" {
+		if indexOptionsSignature(*merged) == "" {
+			merged.Sparse, merged.TTL, merged.Partial, merged.Collation = decl.Sparse, decl.TTL, decl.Partial, decl.Collation
+		} else if indexOptionsSignature(*merged) != indexOptionsSignature(decl) {
+			return errors.New("declared with different options")
+		}
+	}
+
+	for field, weight := range decl.Weights {
+		if merged.Weights == nil {
+			merged.Weights = map[string]int32{}
+		}
+		if w, found := merged.Weights[field]; found && w != weight {
+			return fmt.Errorf("field %s declared with different weights", field)
+		}
+		merged.Weights[field] = weight
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package monk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type SyncIndexOptions struct {
+	DryRun    bool // only work out the plan, do not change anything
+	KeepStale bool // do not drop indexes that are no longer declared
+}
+
+// IndexPlan lists what it takes to bring the indexes of
+// a collection in line with those declared on the model
+type IndexPlan struct {
+	Create    []MonkIndex // declared, but missing
+	Rebuild   []MonkIndex // declared differently from what exists
+	Drop      []string    // exist, but are no longer declared
+	Unchanged []string
+}
+
+// Changes tells if the plan changes anything at all
+func (p IndexPlan) Changes() bool {
+	return len(p.Create) > 0 || len(p.Rebuild) > 0 || len(p.Drop) > 0
+}
+
+// SyncIndexes compares the indexes of the model's collection with the ones
+// declared on the model (see GetAllIndexes), and creates, rebuilds and drops
+// indexes as needed. The plan is returned even when it is a dry run
+func SyncIndexes(ctx context.Context, mc *MongoConn, model interface{}, opts SyncIndexOptions) (IndexPlan, error) {
+
+	existing, err := ListIndexes(ctx, mc, model)
+	if err != nil {
+		return IndexPlan{}, err
+	}
+
+	declared, err := GetAllIndexes(model)
+	if err != nil {
+		return IndexPlan{}, err
+	}
+
+	plan := PlanIndexes(existing, declared, opts)
+	if opts.DryRun || !plan.Changes() {
+		return plan, nil
+	}
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return plan, err
+	}
+	indexes := coll.Indexes()
+
+	drops := append([]string{}, plan.Drop...)
+	for _, idx := range plan.Rebuild {
+		drops = append(drops, idx.Name)
+	}
+	for _, name := range drops {
+		if _, err := indexes.DropOne(ctx, name); err != nil {
+			return plan, fmt.Errorf("could not drop index %s: %w", name, err)
+		}
+	}
+
+	creates := append(append([]MonkIndex{}, plan.Rebuild...), plan.Create...)
+	for _, idx := range creates {
+		if _, err := indexes.CreateOne(ctx, idx.Model()); err != nil {
+			return plan, fmt.Errorf("could not create index %s: %w", idx.Name, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// ListIndexes returns the indexes that exist on the model's
+// collection, other than the one on _id
+func ListIndexes(ctx context.Context, mc *MongoConn, model interface{}) ([]MonkIndex, error) {
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := []struct {
+		Name               string           `bson:"name"`
+		Key                bson.D           `bson:"key"`
+		Unique             bool             `bson:"unique"`
+		Sparse             bool             `bson:"sparse"`
+		ExpireAfterSeconds *int32           `bson:"expireAfterSeconds"`
+		Partial            bson.D           `bson:"partialFilterExpression"`
+		Weights            map[string]int32 `bson:"weights"`
+		Collation          *IndexCollation  `bson:"collation"`
+	}{}
+	if err = cur.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	list := []MonkIndex{}
+	for _, spec := range specs {
+		if spec.Name == "_id_" {
+			continue
+		}
+		idx := MonkIndex{
+			Name:      spec.Name,
+			Unique:    spec.Unique,
+			Fields:    []string{},
+			Order:     []int{},
+			Types:     []string{},
+			Sparse:    spec.Sparse,
+			TTL:       spec.ExpireAfterSeconds,
+			Partial:   spec.Partial,
+			Collation: spec.Collation,
+		}
+		for _, key := range spec.Key {
+			switch key.Key {
+			case "_fts":
+				// Text indexes list their fields under weights
+				for field := range spec.Weights {
+					idx.addField(field, "text")
+				}
+				idx.Weights = spec.Weights
+			case "_ftsx":
+			default:
+				if str, isStr := key.Value.(string); isStr {
+					idx.addField(key.Key, str)
+				} else {
+					idx.addField(key.Key, fmt.Sprint(indexOrder(key.Value)))
+				}
+			}
+		}
+		list = append(list, idx)
+	}
+
+	return list, nil
+}
+
+func indexOrder(v interface{}) int {
+	switch o := v.(type) {
+	case int32:
+		return int(o)
+	case int64:
+		return int(o)
+	case float64:
+		return int(o)
+	}
+	return 0
+}
+
+// PlanIndexes works out the changes needed to go from the
+// existing indexes to the declared ones, matching them by name
+func PlanIndexes(existing []MonkIndex, declared []MonkIndex, opts SyncIndexOptions) IndexPlan {
+	plan := IndexPlan{}
+
+	current := map[string]MonkIndex{}
+	for _, idx := range existing {
+		current[idx.Name] = idx
+	}
+
+	wanted := map[string]bool{}
+	for _, idx := range declared {
+		if wanted[idx.Name] {
+			continue
+		}
+		wanted[idx.Name] = true
+
+		if cur, found := current[idx.Name]; !found {
+			plan.Create = append(plan.Create, idx)
+		} else if sameIndex(cur, idx) {
+			plan.Unchanged = append(plan.Unchanged, idx.Name)
+		} else {
+			plan.Rebuild = append(plan.Rebuild, idx)
+		}
+	}
+
+	if !opts.KeepStale {
+		for _, idx := range existing {
+			if !wanted[idx.Name] {
+				plan.Drop = append(plan.Drop, idx.Name)
+			}
+		}
+	}
+
+	return plan
+}
+
+func sameIndex(a, b MonkIndex) bool {
+	return indexSignature(a) == indexSignature(b)
+}
+
+// indexSignature describes the index in a canonical form: text fields
+// (whose order is not preserved by the server) are sorted, and fields
+// without an explicit weight get the default weight of 1
+func indexSignature(idx MonkIndex) string {
+	keys := []string{}
+	text := []string{}
+	for i, field := range idx.Fields {
+		if idx.key(i) == "text" {
+			weight := int32(1)
+			if w, found := idx.Weights[field]; found {
+				weight = w
+			}
+			text = append(text, fmt.Sprintf("%s:%d", field, weight))
+		} else {
+			keys = append(keys, fmt.Sprintf("%s:%v", field, idx.key(i)))
+		}
+	}
+	sort.Strings(text)
+
+	return fmt.Sprintf("keys=%v text=%v unique=%v", keys, text, idx.Unique) + indexOptionsSignature(idx)
+}
+
+// indexOptionsSignature describes the options (other than
+// uniqueness and weights) of the index in a canonical form
+func indexOptionsSignature(idx MonkIndex) string {
+	sig := ""
+	if idx.Sparse {
+		sig += " sparse"
+	}
+	if idx.TTL != nil {
+		sig += fmt.Sprintf(" ttl=%d", *idx.TTL)
+	}
+	if len(idx.Partial) > 0 {
+		partial, _ := bson.MarshalExtJSON(idx.Partial, false, false)
+		sig += " partial=" + string(partial)
+	}
+	if idx.Collation != nil {
+		strength := idx.Collation.Strength
+		if strength == 0 {
+			strength = 3 // server's default
+		}
+		sig += fmt.Sprintf(" collation=%s:%d", idx.Collation.Locale, strength)
+	}
+	return sig
+}
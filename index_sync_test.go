@@ -0,0 +1,83 @@
+package monk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanIndexes(t *testing.T) {
+
+	existing := []MonkIndex{
+		{Name: "idx_a", Fields: []string{"a"}, Order: []int{1}},
+		{Name: "idx_b", Fields: []string{"b"}, Order: []int{1}},
+		{Name: "idx_old", Fields: []string{"old"}, Order: []int{1}},
+	}
+	declared := []MonkIndex{
+		{Name: "idx_a", Fields: []string{"a"}, Order: []int{1}},
+		{Name: "idx_b", Fields: []string{"b"}, Order: []int{-1}},
+		{Name: "idx_c", Unique: true, Fields: []string{"c"}, Order: []int{1}},
+	}
+
+	plan := PlanIndexes(existing, declared, SyncIndexOptions{})
+	assert.True(t, plan.Changes())
+	assert.Equal(t, []string{"idx_a"}, plan.Unchanged)
+	assert.Equal(t, []MonkIndex{declared[1]}, plan.Rebuild)
+	assert.Equal(t, []MonkIndex{declared[2]}, plan.Create)
+	assert.Equal(t, []string{"idx_old"}, plan.Drop)
+
+	// Stale indexes can be kept
+	plan = PlanIndexes(existing, declared, SyncIndexOptions{KeepStale: true})
+	assert.Len(t, plan.Drop, 0)
+
+	// Nothing to do
+	plan = PlanIndexes(existing[:1], declared[:1], SyncIndexOptions{})
+	assert.False(t, plan.Changes())
+}
+
+type syncIndexTest struct {
+	Field1 string `index:"true"`
+	Field2 string `unique:"true"`
+}
+
+func TestSyncIndexes(t *testing.T) {
+
+	ctx := context.Background()
+
+	// Dry run changes nothing
+	plan, err := SyncIndexes(ctx, &testConnection, syncIndexTest{}, SyncIndexOptions{DryRun: true})
+	assert.Nil(t, err)
+	assert.Len(t, plan.Create, 2)
+	list, _ := ListIndexes(ctx, &testConnection, syncIndexTest{})
+	assert.Len(t, list, 0)
+
+	// Indexes get created, and syncing again has nothing to do
+	_, err = SyncIndexes(ctx, &testConnection, syncIndexTest{}, SyncIndexOptions{})
+	assert.Nil(t, err)
+	plan, err = SyncIndexes(ctx, &testConnection, syncIndexTest{}, SyncIndexOptions{})
+	assert.Nil(t, err)
+	assert.False(t, plan.Changes())
+}
+
+func TestPlanIndexesWithOptions(t *testing.T) {
+
+	ttl := int32(60)
+	declared := []MonkIndex{GetIndex("idx_search(a:text,b:text);weights(a:5)", "a", false)}
+	existing := []MonkIndex{{
+		Name:    "idx_search",
+		Fields:  []string{"b", "a"},
+		Order:   []int{1, 1},
+		Types:   []string{"text", "text"},
+		Weights: map[string]int32{"a": 5, "b": 1},
+	}}
+
+	// Text fields compare regardless of their order
+	plan := PlanIndexes(existing, declared, SyncIndexOptions{})
+	assert.False(t, plan.Changes())
+
+	// A changed option needs a rebuild
+	declared[0].TTL = &ttl
+	plan = PlanIndexes(existing, declared, SyncIndexOptions{})
+	assert.Len(t, plan.Rebuild, 1)
+}
@@ -0,0 +1,206 @@
+package monk
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SchemaValidation configures how MongoDB enforces
+// the $jsonSchema validator generated for a model
+type SchemaValidation struct {
+	Level  string // off | strict [default for new collections] | moderate [default for existing ones]
+	Action string // error [default] | warn
+}
+
+// Models that want other than the default validation
+// level or action, implement this
+type SchemaValidated interface {
+	SchemaValidation() SchemaValidation
+}
+
+// schemaValidationOf returns the validation level and action for the
+// model's collection. Existing collections may hold documents written
+// before the validator, so unless the model asks for strict validation,
+// they are validated moderately (ie. such documents can still be updated)
+func schemaValidationOf(model interface{}, existing bool) SchemaValidation {
+	sv := SchemaValidation{Level: "strict", Action: "error"}
+	if existing {
+		sv.Level = "moderate"
+	}
+
+	var custom SchemaValidation
+	if m, ok := model.(SchemaValidated); ok {
+		custom = m.SchemaValidation()
+	} else if m, ok := newModel(model).(SchemaValidated); ok {
+		custom = m.SchemaValidation()
+	}
+
+	if custom.Level != "" {
+		sv.Level = custom.Level
+	}
+	if custom.Action != "" {
+		sv.Action = custom.Action
+	}
+	return sv
+}
+
+// JsonSchema generates the $jsonSchema for validating the documents of the
+// model. It covers the bson types of fields, the fields required upon insert
+// (insert:"yes") and the enum(...) and rex(...) checks of the verify tag
+func JsonSchema(model interface{}) bson.M {
+	return structSchema(do.TypeDereference(do.TypeOf(model)))
+}
+
+func structSchema(t reflect.Type) bson.M {
+	schema := bson.M{"bsonType": "object"}
+	properties := bson.M{}
+	required := bson.A{}
+
+	walkFields(t, func(sf reflect.StructField) {
+		if !sf.IsExported() || sf.Tag.Get("bson") == "-" {
+			return
+		}
+		key := BsonKey(sf)
+		prop := typeSchema(sf.Type)
+
+		// Checks on slices are of their items (as with Verify),
+		// and nil values (of pointers) are not checked
+		target, nullable := checkedSchema(prop, sf.Type)
+		checks := getFieldTests(sf)
+		if sf.Tag.Get("secret") != "" {
+			// Secrets are stored hashed, and so do not
+			// match the checks of the plain text
+			checks = nil
+		}
+		for _, check := range checks {
+			switch check.Test {
+			case "enum":
+				enum := bson.A{}
+				for _, val := range strings.Split(check.Option, "|") {
+					if val != "" {
+						enum = append(enum, val)
+					}
+				}
+				if nullable {
+					enum = append(enum, nil)
+				}
+				target["enum"] = enum
+			case "rex":
+				target["pattern"] = check.Option
+			}
+		}
+		properties[key] = prop
+		if sf.Tag.Get("insert") == "yes" {
+			required = append(required, key)
+		}
+	})
+
+	if len(properties) > 0 {
+		schema["properties"] = properties
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// checkedSchema returns the part of the field's schema that the verify
+// checks apply to (the items', for slices), and whether it may be null
+func checkedSchema(prop bson.M, t reflect.Type) (bson.M, bool) {
+	et := do.TypeDereference(t)
+	if items, isList := prop["items"].(bson.M); isList && (et.Kind() == reflect.Slice || et.Kind() == reflect.Array) {
+		return items, et.Elem().Kind() == reflect.Ptr
+	}
+	return prop, t.Kind() == reflect.Ptr
+}
+
+var objectIDType = reflect.TypeOf(primitive.ObjectID{})
+
+func typeSchema(t reflect.Type) bson.M {
+
+	// Pointers may be nil
+	if t.Kind() == reflect.Ptr {
+		schema := typeSchema(t.Elem())
+		if bt, ok := schema["bsonType"]; ok {
+			schema["bsonType"] = append(bsonTypes(bt), "null")
+		}
+		return schema
+	}
+
+	switch {
+	case do.TypeIsTime(t):
+		return bson.M{"bsonType": "date"}
+	case t == objectIDType:
+		return bson.M{"bsonType": "objectId"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return bson.M{"bsonType": "string"}
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// The driver stores integers as int or long, depending on their size
+		return bson.M{"bsonType": bson.A{"int", "long"}}
+	case reflect.Float32, reflect.Float64:
+		return bson.M{"bsonType": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bson.M{"bsonType": "binData"}
+		}
+		return bson.M{"bsonType": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return bson.M{"bsonType": "object"}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	// Anything goes (eg. interface{})
+	return bson.M{}
+}
+
+func bsonTypes(bt interface{}) bson.A {
+	if list, ok := bt.(bson.A); ok {
+		return append(bson.A{}, list...)
+	}
+	return bson.A{bt}
+}
+
+// ApplySchemaValidation creates the model's collection with the generated
+// $jsonSchema validator, or if the collection exists, updates its validator
+// (see schemaValidationOf for the level used then)
+func ApplySchemaValidation(ctx context.Context, mc *MongoConn, model interface{}) error {
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return err
+	}
+	sv := schemaValidationOf(model, false)
+	validator := bson.M{"$jsonSchema": JsonSchema(model)}
+
+	err = coll.Database().RunCommand(ctx, bson.D{
+		{Key: "create", Value: coll.Name()},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: sv.Level},
+		{Key: "validationAction", Value: sv.Action},
+	}).Err()
+
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == 48 /* NamespaceExists */ {
+		sv = schemaValidationOf(model, true)
+		err = coll.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: sv.Level},
+			{Key: "validationAction", Value: sv.Action},
+		}).Err()
+	}
+
+	return err
+}
@@ -0,0 +1,74 @@
+package monk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type schemaTest struct {
+	ID      string    `bson:"_id"`
+	Email   string    `bson:"email" insert:"yes" verify:"rex(^.+@.+$)"`
+	Color   *string   `bson:"color" verify:"enum(red|green)"`
+	Count   uint      `bson:"count"`
+	Score   float64   `bson:"score"`
+	Tags    []string  `bson:"tags" verify:"enum(a|b)"`
+	Codes   []*string `bson:"codes" verify:"rex(^[A-Z]+$)"`
+	Pin     string    `bson:"pin" secret:"bcrypt" verify:"rex(^[0-9]{4}$)"`
+	When    time.Time `bson:"when"`
+	Address *Address  `bson:"address"`
+	Timed   `bson:",inline"`
+}
+
+type schemaTestWarn struct{}
+
+func (schemaTestWarn) SchemaValidation() SchemaValidation {
+	return SchemaValidation{Action: "warn"}
+}
+
+type schemaTestStrict struct{}
+
+func (schemaTestStrict) SchemaValidation() SchemaValidation {
+	return SchemaValidation{Level: "strict"}
+}
+
+func TestJsonSchema(t *testing.T) {
+
+	schema := JsonSchema(&schemaTest{})
+	props := schema["properties"].(bson.M)
+
+	assert.Equal(t, "object", schema["bsonType"])
+	assert.Equal(t, bson.A{"email"}, schema["required"])
+
+	assert.Equal(t, bson.M{"bsonType": "string"}, props["_id"])
+	assert.Equal(t, bson.M{"bsonType": "string", "pattern": "^.+@.+$"}, props["email"])
+	assert.Equal(t, bson.M{"bsonType": bson.A{"string", "null"}, "enum": bson.A{"red", "green", nil}}, props["color"])
+	assert.Equal(t, bson.M{"bsonType": bson.A{"int", "long"}}, props["count"])
+	assert.Equal(t, bson.M{"bsonType": "number"}, props["score"])
+	assert.Equal(t, bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string", "enum": bson.A{"a", "b"}}}, props["tags"])
+	assert.Equal(t, bson.M{"bsonType": "array", "items": bson.M{"bsonType": bson.A{"string", "null"}, "pattern": "^[A-Z]+$"}}, props["codes"])
+
+	// Secrets are checked before they are hashed, not by the schema
+	assert.Equal(t, bson.M{"bsonType": "string"}, props["pin"])
+	assert.Equal(t, bson.M{"bsonType": "date"}, props["when"])
+
+	// Embedded structs are inlined
+	assert.Equal(t, bson.M{"bsonType": "date"}, props["created_at"])
+
+	// Nested structs get their own properties
+	address := props["address"].(bson.M)
+	assert.Equal(t, bson.A{"object", "null"}, address["bsonType"])
+	assert.Equal(t, bson.M{"bsonType": "string"}, address["properties"].(bson.M)["city"])
+}
+
+func TestSchemaValidationOf(t *testing.T) {
+	assert.Equal(t, SchemaValidation{"strict", "error"}, schemaValidationOf(schemaTest{}, false))
+	assert.Equal(t, SchemaValidation{"strict", "warn"}, schemaValidationOf(schemaTestWarn{}, false))
+
+	// Existing collections are validated strictly only if asked for
+	assert.Equal(t, SchemaValidation{"moderate", "error"}, schemaValidationOf(schemaTest{}, true))
+	assert.Equal(t, SchemaValidation{"moderate", "warn"}, schemaValidationOf(schemaTestWarn{}, true))
+	assert.Equal(t, SchemaValidation{"strict", "error"}, schemaValidationOf(schemaTestStrict{}, true))
+}
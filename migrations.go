@@ -0,0 +1,306 @@
+package monk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+/*
+	MIGRATIONS
+
+	Migrations run in the order of their IDs (so prefix them with
+	a date or a sequence number), and the IDs of the ones applied to
+	a database are recorded in its "monk_migrations" collection. The
+	same collection holds the lock that keeps runners from stepping
+	on each other
+*/
+
+const MigrationsCollection = "monk_migrations"
+
+const migrationLockID = "monk_lock"
+
+// A lock older than this is taken to be left behind by a runner
+// that died, and is taken over. Runners refresh their lock well
+// within it, for as long as they hold it
+var MigrationLockTimeout = 30 * time.Minute
+
+var ErrMigrationLocked = errors.New("migrations are being run by another runner")
+
+var ErrMigrationLockLost = errors.New("migrations lock was taken over by another runner")
+
+type Migration struct {
+	ID   string
+	Up   func(ctx context.Context, mc *MongoConn) error
+	Down func(ctx context.Context, mc *MongoConn) error // optional
+}
+
+type MigrationStatus struct {
+	ID         string
+	Applied    bool
+	AppliedAt  *time.Time
+	Registered bool // false, if applied but no longer registered
+}
+
+// Migrations is a set of migrations, that can be
+// run against any number of databases
+type Migrations struct {
+	mu   sync.RWMutex
+	list []Migration
+}
+
+// Register adds migrations to the set. IDs must be unique
+func (ms *Migrations) Register(migrations ...Migration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, m := range migrations {
+		if m.ID == "" || m.ID == migrationLockID {
+			return fmt.Errorf("invalid migration id %q", m.ID)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("migration %s has no Up", m.ID)
+		}
+		if _, found := ms.find(m.ID); found {
+			return fmt.Errorf("migration %s is already registered", m.ID)
+		}
+		ms.list = append(ms.list, m)
+	}
+
+	sort.Slice(ms.list, func(i, j int) bool {
+		return ms.list[i].ID < ms.list[j].ID
+	})
+	return nil
+}
+
+func (ms *Migrations) find(id string) (Migration, bool) {
+	for _, m := range ms.list {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Migrate applies the pending migrations, in order, and returns the IDs
+// of the ones applied. It stops at the first migration that fails
+func (ms *Migrations) Migrate(ctx context.Context, mc *MongoConn) ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	done := []string{}
+	err := withMigrationLock(ctx, mc, func(lock *migrationLock) error {
+		applied, err := appliedMigrations(ctx, lock.coll)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range ms.list {
+			if _, found := applied[m.ID]; found {
+				continue
+			}
+			if err := lock.refresh(ctx); err != nil {
+				return err
+			}
+			if err := m.Up(ctx, mc); err != nil {
+				return fmt.Errorf("migration %s: %w", m.ID, err)
+			}
+			_, err := lock.coll.InsertOne(ctx, bson.M{"_id": m.ID, "applied_at": time.Now()})
+			if err != nil {
+				return fmt.Errorf("migration %s applied, but not recorded: %w", m.ID, err)
+			}
+			done = append(done, m.ID)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Rollback reverts the last n applied migrations, latest first, and
+// returns the IDs of the ones reverted
+func (ms *Migrations) Rollback(ctx context.Context, mc *MongoConn, n int) ([]string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	done := []string{}
+	err := withMigrationLock(ctx, mc, func(lock *migrationLock) error {
+		applied, err := appliedMigrations(ctx, lock.coll)
+		if err != nil {
+			return err
+		}
+
+		ids := []string{}
+		for id := range applied {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			ti, tj := applied[ids[i]], applied[ids[j]]
+			if !ti.Equal(tj) {
+				return ti.After(tj)
+			}
+			return ids[i] > ids[j]
+		})
+
+		for i := 0; i < n && i < len(ids); i++ {
+			m, found := ms.find(ids[i])
+			if !found {
+				return fmt.Errorf("migration %s is not registered", ids[i])
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migration %s cannot be rolled back", m.ID)
+			}
+			if err := lock.refresh(ctx); err != nil {
+				return err
+			}
+			if err := m.Down(ctx, mc); err != nil {
+				return fmt.Errorf("migration %s: %w", m.ID, err)
+			}
+			if _, err := lock.coll.DeleteOne(ctx, bson.M{"_id": m.ID}); err != nil {
+				return fmt.Errorf("migration %s rolled back, but not recorded: %w", m.ID, err)
+			}
+			done = append(done, m.ID)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists the registered migrations, in order, telling which are applied.
+// Migrations that are applied but no longer registered are listed at the end
+func (ms *Migrations) Status(ctx context.Context, mc *MongoConn) ([]MigrationStatus, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	db, err := mc.Database()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db.Collection(MigrationsCollection))
+	if err != nil {
+		return nil, err
+	}
+
+	list := []MigrationStatus{}
+	for _, m := range ms.list {
+		st := MigrationStatus{ID: m.ID, Registered: true}
+		if at, found := applied[m.ID]; found {
+			st.Applied = true
+			st.AppliedAt = &at
+			delete(applied, m.ID)
+		}
+		list = append(list, st)
+	}
+
+	unknown := []string{}
+	for id := range applied {
+		unknown = append(unknown, id)
+	}
+	sort.Strings(unknown)
+	for _, id := range unknown {
+		at := applied[id]
+		list = append(list, MigrationStatus{ID: id, Applied: true, AppliedAt: &at})
+	}
+
+	return list, nil
+}
+
+func appliedMigrations(ctx context.Context, coll *mongo.Collection) (map[string]time.Time, error) {
+	cur, err := coll.Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockID}})
+	if err != nil {
+		return nil, err
+	}
+
+	records := []struct {
+		ID        string    `bson:"_id"`
+		AppliedAt time.Time `bson:"applied_at"`
+	}{}
+	if err = cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := map[string]time.Time{}
+	for _, r := range records {
+		applied[r.ID] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+// migrationLock is a runner's hold on the lock on the migrations
+type migrationLock struct {
+	coll  *mongo.Collection
+	owner string
+}
+
+// refresh renews the lock, so that it does not go stale while
+// held, or returns ErrMigrationLockLost if it is no longer held
+func (l *migrationLock) refresh(ctx context.Context) error {
+	res, err := l.coll.UpdateOne(ctx,
+		bson.M{"_id": migrationLockID, "owner": l.owner},
+		bson.M{"$set": bson.M{"locked_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrMigrationLockLost
+	}
+	return nil
+}
+
+// withMigrationLock runs fn holding the lock on the migrations of the
+// database. The lock is a document that only one runner can upsert
+// (others run into a duplicate key), unless it has gone stale. It is
+// refreshed in the background while fn runs
+func withMigrationLock(ctx context.Context, mc *MongoConn, fn func(*migrationLock) error) error {
+	db, err := mc.Database()
+	if err != nil {
+		return err
+	}
+	coll := db.Collection(MigrationsCollection)
+	owner := uuid.NewString()
+	now := time.Now()
+
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"_id": migrationLockID, "locked_at": bson.M{"$lt": now.Add(-MigrationLockTimeout)}},
+		bson.M{"$set": bson.M{"owner": owner, "locked_at": now}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrMigrationLocked
+	}
+	if err != nil {
+		return err
+	}
+
+	defer coll.DeleteOne(context.Background(), bson.M{"_id": migrationLockID, "owner": owner})
+
+	lock := &migrationLock{coll: coll, owner: owner}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(MigrationLockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// Failures show up when
+				// the lock is next checked
+				lock.refresh(ctx)
+			}
+		}
+	}()
+
+	return fn(lock)
+}
@@ -0,0 +1,96 @@
+package monk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func noopMigration(ctx context.Context, mc *MongoConn) error {
+	return nil
+}
+
+func TestRegisterMigrations(t *testing.T) {
+
+	ms := Migrations{}
+	assert.Nil(t, ms.Register(
+		Migration{ID: "002-b", Up: noopMigration},
+		Migration{ID: "001-a", Up: noopMigration},
+	))
+
+	// Ordered by ID
+	assert.Equal(t, "001-a", ms.list[0].ID)
+	assert.Equal(t, "002-b", ms.list[1].ID)
+
+	assert.NotNil(t, ms.Register(Migration{ID: "001-a", Up: noopMigration}))
+	assert.NotNil(t, ms.Register(Migration{ID: "", Up: noopMigration}))
+	assert.NotNil(t, ms.Register(Migration{ID: migrationLockID, Up: noopMigration}))
+	assert.NotNil(t, ms.Register(Migration{ID: "003-c"}))
+}
+
+func TestMigrations(t *testing.T) {
+
+	ctx := context.Background()
+	db, err := testConnection.Database()
+	assert.Nil(t, err)
+	coll := db.Collection("migrated")
+
+	ms := Migrations{}
+	err = ms.Register(
+		Migration{
+			ID: "001-insert",
+			Up: func(ctx context.Context, mc *MongoConn) error {
+				_, err := coll.InsertOne(ctx, bson.M{"_id": "x"})
+				return err
+			},
+			Down: func(ctx context.Context, mc *MongoConn) error {
+				_, err := coll.DeleteOne(ctx, bson.M{"_id": "x"})
+				return err
+			},
+		},
+		Migration{ID: "002-noop", Up: noopMigration, Down: noopMigration},
+	)
+	assert.Nil(t, err)
+
+	done, err := ms.Migrate(ctx, &testConnection)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"001-insert", "002-noop"}, done)
+
+	// Nothing more to apply
+	done, err = ms.Migrate(ctx, &testConnection)
+	assert.Nil(t, err)
+	assert.Empty(t, done)
+
+	status, err := ms.Status(ctx, &testConnection)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(status))
+	assert.True(t, status[0].Applied)
+	assert.True(t, status[1].Applied)
+
+	// Runners are kept apart by the lock
+	err = withMigrationLock(ctx, &testConnection, func(*migrationLock) error {
+		_, err := ms.Migrate(ctx, &testConnection)
+		return err
+	})
+	assert.Equal(t, ErrMigrationLocked, err)
+
+	// A runner whose lock was taken over stops
+	err = withMigrationLock(ctx, &testConnection, func(lock *migrationLock) error {
+		_, err := lock.coll.UpdateOne(ctx, bson.M{"_id": migrationLockID}, bson.M{"$set": bson.M{"owner": "other"}})
+		assert.Nil(t, err)
+		return lock.refresh(ctx)
+	})
+	assert.Equal(t, ErrMigrationLockLost, err)
+	_, err = db.Collection(MigrationsCollection).DeleteOne(ctx, bson.M{"_id": migrationLockID})
+	assert.Nil(t, err)
+
+	done, err = ms.Rollback(ctx, &testConnection, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"002-noop", "001-insert"}, done)
+
+	count, err := coll.CountDocuments(ctx, bson.M{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, count)
+}
@@ -1,33 +1,18 @@
 package monk
 
 import (
+	"reflect"
 	"time"
 
 	"github.com/outerjoin/do"
 )
 
-/*
-type PreInsertHook interface {
-	BeforeInsert(input Map) error
-}
-
-type PreUpdateHook interface {
-	BeforeUpdate(input Map) error
-}
-*/
-
 type MongoStore struct {
 }
 
 type MysqlStore struct {
 }
 
-var globalHook = struct {
-	Timed
-}{
-	Timed{},
-}
-
 type OptionalBehaviors struct {
 	Address    bool
 	Coordinate bool
@@ -81,8 +66,10 @@ type Reference struct {
 	RefUID  string
 }
 
+// Versioned documents are updated optimistically: every update
+// must carry the version the caller last read, and bumps it by one
 type Versioned struct {
-	Version int
+	Version int `bson:"version" json:"version" insert:"no" update:"no"`
 }
 
 func (Versioned) BeforeInsert(input do.Map) error {
@@ -102,8 +89,8 @@ type Active1 struct {
 }
 
 type Timed struct {
-	CreatedAt time.Time `bson:"created_at" json:"created_at" index:"true"`
-	UpdatedAt time.Time `bson:"updated_at" json:"updated_at" index:"true"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at" index:"true" filter:"yes"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at" index:"true" filter:"yes"`
 }
 
 func (Timed) BeforeInsert(input do.Map) error {
@@ -163,8 +150,11 @@ type Activated0 struct {
 }
 
 type Deletable struct {
-	// All queries assume Deleted=0
-	Deleted uint
+	// All queries assume Deleted!=1 (missing, for documents written
+	// before the model was Deletable). The index is not sparse, so that
+	// it covers such documents as well
+	Deleted   uint       `bson:"deleted" json:"deleted" insert:"no" update:"no" index:"true"`
+	DeletedAt *time.Time `bson:"deleted_at" json:"deleted_at" insert:"no" update:"no"`
 }
 
 func (Deletable) BeforeInsert(input do.Map) error {
@@ -179,6 +169,40 @@ func (Deletable) BeforeDelete(input do.Map) error {
 	return nil
 }
 
+func isDeletable(model interface{}) bool {
+	return composedOf(model, Deletable{})
+}
+
+func isVersioned(model interface{}) bool {
+	return composedOf(model, Versioned{})
+}
+
+// insertMarks returns the fields that Versioned and Deletable
+// models keep, as they are upon insert
+func insertMarks(model interface{}) do.Map {
+	marks := do.Map{}
+	if isVersioned(model) {
+		marks["version"] = 1
+	}
+	if isDeletable(model) {
+		marks["deleted"] = 0
+	}
+	return marks
+}
+
+// deleteMarks returns the fields that mark
+// documents of Deletable models as deleted
+func deleteMarks() do.Map {
+	return do.Map{"deleted": 1, "deleted_at": time.Now()}
+}
+
+// composedOf is like do.TypeComposedOf, but is safe to call
+// on models that are not structs (eg. collection names)
+func composedOf(model interface{}, mixin interface{}) bool {
+	t := do.TypeDereference(do.TypeOf(model))
+	return t.Kind() == reflect.Struct && do.TypeComposedOf(t, mixin)
+}
+
 type CustomFields struct {
 	Custom *map[string]interface{}
 }
@@ -210,16 +234,16 @@ type DataDogNewRelic struct {
 type User struct {
 	UUID     string `bson:"_id" json:"uuid"`
 	Username string `bson:"username" json:"username"`
-	Password string `bson:"password" json:"password"`
+	Password string `bson:"password" json:"password" secret:"bcrypt"` // serialize Users with ToJSON, not json.Marshal
 
 	// Role (enum)
 
 	AccountUUID string `bson:"account_uuid" json:"account_uuid"`
 
-	Activated0
-	CustomFields
-	Tagged
-	Timed
+	Activated0   `bson:",inline"`
+	CustomFields `bson:",inline"`
+	Tagged       `bson:",inline"`
+	Timed        `bson:",inline"`
 }
 
 type Account struct {
@@ -227,10 +251,10 @@ type Account struct {
 
 	// AccountLevel (enum)
 
-	Deletable
-	CustomFields
-	Tagged
-	Timed
+	Deletable    `bson:",inline"`
+	CustomFields `bson:",inline"`
+	Tagged       `bson:",inline"`
+	Timed        `bson:",inline"`
 }
 
 type Environment struct {
@@ -241,11 +265,11 @@ type Environment struct {
 
 	TelemetryConfig *TelemetryConfig `bson:"telemetry_config" json:"telemetry_config"`
 
-	Activated1
-	Deletable
-	CustomFields
-	Tagged
-	Timed
+	Activated1   `bson:",inline"`
+	Deletable    `bson:",inline"`
+	CustomFields `bson:",inline"`
+	Tagged       `bson:",inline"`
+	Timed        `bson:",inline"`
 }
 
 type Instance struct {
@@ -261,9 +285,9 @@ type Instance struct {
 	AllowUpload int                `bson:"allow_upload" json:"allow_upload"`
 	DoTelemetry int                `bson:"do_telemetry" json:"do_telemetry"`
 
-	Activated1
-	Deletable
-	CustomFields
-	Tagged
-	Timed
+	Activated1   `bson:",inline"`
+	Deletable    `bson:",inline"`
+	CustomFields `bson:",inline"`
+	Tagged       `bson:",inline"`
+	Timed        `bson:",inline"`
 }
@@ -2,11 +2,15 @@ package monk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type MongoConn struct {
@@ -16,32 +20,164 @@ type MongoConn struct {
 	Coll       string // optional
 	CollSuffix string // optional
 
-	// for reuse
+	// Used only by the first MongoConn that opens a connection
+	// to ConnStr. Others may leave it empty, or give the same
+	// options; Client returns an error if they differ
+	Pool PoolOptions // optional
+
+	ref *clientRef // held on the shared client, once used
+}
+
+// PoolOptions tune the connection pool of the client. Zero
+// values leave the driver's defaults in place
+type PoolOptions struct {
+	MaxPoolSize            uint64
+	MinPoolSize            uint64
+	MaxConnIdleTime        time.Duration
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+}
+
+func (po PoolOptions) apply(opts *options.ClientOptions) *options.ClientOptions {
+	if po.MaxPoolSize != 0 {
+		opts.SetMaxPoolSize(po.MaxPoolSize)
+	}
+	if po.MinPoolSize != 0 {
+		opts.SetMinPoolSize(po.MinPoolSize)
+	}
+	if po.MaxConnIdleTime != 0 {
+		opts.SetMaxConnIdleTime(po.MaxConnIdleTime)
+	}
+	if po.ConnectTimeout != 0 {
+		opts.SetConnectTimeout(po.ConnectTimeout)
+	}
+	if po.ServerSelectionTimeout != 0 {
+		opts.SetServerSelectionTimeout(po.ServerSelectionTimeout)
+	}
+	return opts
+}
+
+// Clients are safe for concurrent use and maintain their own
+// connection pool, so one client is shared by all the MongoConns
+// having the same connection string. It is disconnected once all
+// the MongoConns that used it are closed
+var clients = struct {
+	sync.Mutex
+	byConnStr map[string]*sharedClient
+}{
+	byConnStr: map[string]*sharedClient{},
+}
+
+type sharedClient struct {
 	client *mongo.Client
+	pool   PoolOptions
+	refs   int
+}
+
+// clientRef is a MongoConn's hold on a shared client. Copies
+// of the MongoConn share it, and so are released together
+type clientRef struct {
+	shared   *sharedClient
+	released bool
 }
 
-func (mc *MongoConn) GetClient() *mongo.Client {
+// Client returns the (shared) client for the connection string,
+// connecting to the database upon first use
+func (mc *MongoConn) Client() (*mongo.Client, error) {
+	clients.Lock()
+	defer clients.Unlock()
+
+	shared, found := clients.byConnStr[mc.ConnStr]
+	if found && mc.Pool != (PoolOptions{}) && mc.Pool != shared.pool {
+		return nil, fmt.Errorf("pool options differ from those of the open client for %s", mc.ConnStr)
+	}
+
+	if !found {
+		opts := mc.Pool.apply(options.Client().ApplyURI(mc.ConnStr))
+		client, err := mongo.Connect(context.TODO(), opts)
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedClient{client: client, pool: mc.Pool}
+		clients.byConnStr[mc.ConnStr] = shared
+	}
 
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(mc.ConnStr))
+	if mc.ref == nil || mc.ref.released || mc.ref.shared != shared {
+		shared.refs++
+		mc.ref = &clientRef{shared: shared}
+	}
+	return shared.client, nil
+}
+
+// GetClient is Client, logging the errors
+func (mc *MongoConn) GetClient() (*mongo.Client, error) {
+
+	client, err := mc.Client()
 	if err != nil {
 		log.Error().
 			Err(err).
 			Str("connection string", mc.ConnStr).
 			Msg("unable to open connection")
+		return nil, err
+	}
+	return client, nil
+}
+
+// Ping checks that the database server can be reached
+func (mc *MongoConn) Ping() error {
+	client, err := mc.Client()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	return client.Ping(ctx, readpref.Primary())
+}
+
+// Close releases the MongoConn's hold on the shared client, which
+// is disconnected when the last MongoConn using it is closed. A
+// closed MongoConn connects afresh upon its next use
+func (mc *MongoConn) Close() error {
+	clients.Lock()
+	ref := mc.ref
+	mc.ref = nil
+	if ref == nil || ref.released {
+		clients.Unlock()
 		return nil
 	}
-	return client
+
+	ref.released = true
+	shared := ref.shared
+	shared.refs--
+	if shared.refs > 0 || clients.byConnStr[mc.ConnStr] != shared {
+		clients.Unlock()
+		return nil
+	}
+	delete(clients.byConnStr, mc.ConnStr)
+	clients.Unlock()
+
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	return shared.client.Disconnect(ctx)
 }
 
-func (mc *MongoConn) Database() *mongo.Database {
+func (mc *MongoConn) Database() (*mongo.Database, error) {
+	client, err := mc.GetClient()
+	if err != nil {
+		return nil, err
+	}
+
 	if mc.DBSuffix == "" {
-		return mc.GetClient().Database(mc.DB)
+		return client.Database(mc.DB), nil
 	} else {
-		return mc.GetClient().Database(fmt.Sprintf("%s-%s", mc.DB, mc.DBSuffix))
+		return client.Database(fmt.Sprintf("%s-%s", mc.DB, mc.DBSuffix)), nil
 	}
 }
 
-func (mc *MongoConn) Collection(model ...interface{}) *mongo.Collection {
+func (mc *MongoConn) Collection(model ...interface{}) (*mongo.Collection, error) {
 
 	coll := ""
 	if len(model) == 0 {
@@ -50,17 +186,45 @@ func (mc *MongoConn) Collection(model ...interface{}) *mongo.Collection {
 		coll = CollectionName(model[0])
 	}
 
+	db, err := mc.Database()
+	if err != nil {
+		return nil, err
+	}
+
 	if mc.CollSuffix == "" {
-		return mc.Database().Collection(coll)
+		return db.Collection(coll), nil
 	} else {
-		return mc.Database().Collection(fmt.Sprintf("%s-%s", coll, mc.CollSuffix))
+		return db.Collection(fmt.Sprintf("%s-%s", coll, mc.CollSuffix)), nil
 	}
 }
 
-func NewMongoConnToDB(envUUID string) MongoConn {
-	return MongoConn{}
+// NewMongoConnToDB returns a connection to the database of the
+// environment, as configured (see ResolveConfig)
+func NewMongoConnToDB(envUUID string) (MongoConn, error) {
+	c, err := ResolveConfig(envUUID)
+	if err != nil {
+		return MongoConn{}, err
+	}
+	if c.DB == "" {
+		return MongoConn{}, errors.New("database name is not configured")
+	}
+
+	return MongoConn{
+		ConnStr:    c.ConnStr,
+		DB:         c.DB,
+		DBSuffix:   c.DBSuffix,
+		CollSuffix: c.CollSuffix,
+	}, nil
 }
 
-func NewMongoConnToColl(envUUID string, collUUID string) MongoConn {
-	return MongoConn{}
+// NewMongoConnToColl returns a connection to the given
+// collection, in the database of the environment
+func NewMongoConnToColl(envUUID string, collUUID string) (MongoConn, error) {
+	mc, err := NewMongoConnToDB(envUUID)
+	if err != nil {
+		return mc, err
+	}
+
+	mc.Coll = collUUID
+	return mc, nil
 }
@@ -1 +1,70 @@
 package monk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIsShared(t *testing.T) {
+
+	a := MongoConn{ConnStr: "mongodb://127.0.0.1:27018/", DB: "a"}
+	b := MongoConn{ConnStr: "mongodb://127.0.0.1:27018/", DB: "b"}
+
+	ca, err := a.Client()
+	assert.Nil(t, err)
+	cb, err := b.Client()
+	assert.Nil(t, err)
+	assert.True(t, ca == cb)
+
+	// The client stays open until all
+	// that used it are closed
+	assert.Nil(t, a.Close())
+	assert.Nil(t, a.Close())
+	cb2, err := b.Client()
+	assert.Nil(t, err)
+	assert.True(t, cb == cb2)
+
+	assert.Nil(t, b.Close())
+	ca2, err := a.Client()
+	assert.Nil(t, err)
+	assert.False(t, ca == ca2)
+	assert.Nil(t, a.Close())
+}
+
+func TestClientRefs(t *testing.T) {
+
+	a := MongoConn{ConnStr: "mongodb://127.0.0.1:27019/", DB: "a", Pool: PoolOptions{MaxPoolSize: 10}}
+	ca, err := a.Client()
+	assert.Nil(t, err)
+
+	// Copies share the hold of the MongoConn
+	c := a
+	assert.Nil(t, c.Close())
+	assert.Nil(t, a.Close())
+	_, found := clients.byConnStr[a.ConnStr]
+	assert.False(t, found)
+
+	// Pool options cannot differ from those of the open client
+	ca, err = a.Client()
+	assert.Nil(t, err)
+	b := MongoConn{ConnStr: a.ConnStr, DB: "b", Pool: PoolOptions{MaxPoolSize: 20}}
+	_, err = b.Client()
+	assert.NotNil(t, err)
+
+	// And neither the database nor collections are handed out then
+	db, err := b.Database()
+	assert.NotNil(t, err)
+	assert.Nil(t, db)
+	coll, err := b.Collection("c")
+	assert.NotNil(t, err)
+	assert.Nil(t, coll)
+
+	b.Pool = PoolOptions{}
+	cb, err := b.Client()
+	assert.Nil(t, err)
+	assert.True(t, ca == cb)
+
+	assert.Nil(t, a.Close())
+	assert.Nil(t, b.Close())
+}
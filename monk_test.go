@@ -29,8 +29,12 @@ func TestMain(m *testing.M) {
 		{
 			ctx, cancel := GetContext()
 			defer cancel()
-			testConnection.Database().Drop(ctx)
+			if db, err := testConnection.Database(); err == nil {
+				db.Drop(ctx)
+			}
 		}
+
+		testConnection.Close()
 	}()
 
 	os.Exit(retCode)
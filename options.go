@@ -0,0 +1,121 @@
+package monk
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryOption tweaks the behaviour of the find, count,
+// update and delete operations
+type QueryOption func(*queryConfig)
+
+type queryConfig struct {
+	includeDeleted bool
+
+	sort  bson.D
+	skip  int64
+	limit int64
+
+	selected []string
+	roles    []string
+}
+
+// IncludeDeleted makes operations on Deletable models
+// consider the soft deleted documents as well
+var IncludeDeleted QueryOption = func(qc *queryConfig) {
+	qc.includeDeleted = true
+}
+
+// Sort orders the documents found by the given keys
+// (1 for ascending, -1 for descending order)
+func Sort(keys bson.D) QueryOption {
+	return func(qc *queryConfig) {
+		qc.sort = keys
+	}
+}
+
+// Skip leaves out the first n documents found
+func Skip(n int64) QueryOption {
+	return func(qc *queryConfig) {
+		qc.skip = n
+	}
+}
+
+// Limit returns no more than n documents. Count
+// is not limited, so that it tells the total
+func Limit(n int64) QueryOption {
+	return func(qc *queryConfig) {
+		qc.limit = n
+	}
+}
+
+func newQueryConfig(opts []QueryOption) *queryConfig {
+	qc := &queryConfig{}
+	for _, opt := range opts {
+		opt(qc)
+	}
+	return qc
+}
+
+// scope narrows the caller's filter down to the documents the
+// operation is allowed to see (eg. skipping soft deleted ones)
+func (qc *queryConfig) scope(model interface{}, filter interface{}) interface{} {
+
+	conditions := bson.A{}
+	if filter != nil {
+		conditions = append(conditions, filter)
+	}
+
+	if isDeletable(model) && !qc.includeDeleted {
+		// Documents written before the model was Deletable
+		// have no deleted field, and are not deleted either
+		conditions = append(conditions, bson.M{"deleted": bson.M{"$ne": 1}})
+	}
+
+	switch len(conditions) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return conditions[0]
+	}
+	return bson.M{"$and": conditions}
+}
+
+func (qc *queryConfig) findOptions(model interface{}) (*options.FindOptions, error) {
+	opts := options.Find()
+	proj, err := qc.projection(model)
+	if err != nil {
+		return nil, err
+	}
+	if len(proj) > 0 {
+		opts.SetProjection(proj)
+	}
+	if len(qc.sort) > 0 {
+		opts.SetSort(qc.sort)
+	}
+	if qc.skip > 0 {
+		opts.SetSkip(qc.skip)
+	}
+	if qc.limit > 0 {
+		opts.SetLimit(qc.limit)
+	}
+	return opts, nil
+}
+
+func (qc *queryConfig) findOneOptions(model interface{}) (*options.FindOneOptions, error) {
+	opts := options.FindOne()
+	proj, err := qc.projection(model)
+	if err != nil {
+		return nil, err
+	}
+	if len(proj) > 0 {
+		opts.SetProjection(proj)
+	}
+	if len(qc.sort) > 0 {
+		opts.SetSort(qc.sort)
+	}
+	if qc.skip > 0 {
+		opts.SetSkip(qc.skip)
+	}
+	return opts, nil
+}
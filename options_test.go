@@ -0,0 +1,54 @@
+package monk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestScopeDeletable(t *testing.T) {
+
+	// Models that are not Deletable are not touched
+	{
+		qc := newQueryConfig(nil)
+		assert.Equal(t, bson.M{}, qc.scope(crudTest{}, nil))
+		assert.Equal(t, bson.M{"name": "a"}, qc.scope(crudTest{}, bson.M{"name": "a"}))
+	}
+
+	// Deletable models only see documents not marked deleted (including
+	// those written before the model was Deletable)
+	{
+		qc := newQueryConfig(nil)
+		notDeleted := bson.M{"deleted": bson.M{"$ne": 1}}
+		assert.Equal(t, notDeleted, qc.scope(Account{}, nil))
+		assert.Equal(t, bson.M{"$and": bson.A{bson.M{"_id": "a"}, notDeleted}}, qc.scope(&Account{}, bson.M{"_id": "a"}))
+	}
+
+	// Unless asked to include deleted ones
+	{
+		qc := newQueryConfig([]QueryOption{IncludeDeleted})
+		assert.Equal(t, bson.M{}, qc.scope(Account{}, nil))
+	}
+}
+
+func TestFindOptions(t *testing.T) {
+
+	qc := newQueryConfig([]QueryOption{Sort(bson.D{{Key: "name", Value: -1}}), Skip(10), Limit(5)})
+
+	fo, err := qc.findOptions(crudTest{})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{{Key: "name", Value: -1}}, fo.Sort)
+	assert.EqualValues(t, 10, *fo.Skip)
+	assert.EqualValues(t, 5, *fo.Limit)
+
+	foo, err := qc.findOneOptions(crudTest{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 10, *foo.Skip)
+
+	// Nothing is set, unless asked for
+	fo, _ = newQueryConfig(nil).findOptions(crudTest{})
+	assert.Nil(t, fo.Sort)
+	assert.Nil(t, fo.Limit)
+	assert.Nil(t, fo.Projection)
+}
@@ -0,0 +1,377 @@
+package monk
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Number of documents in a page, if the request does not say
+var DefaultPageLimit int64 = 20
+
+// Pages are ordered by these keys, if the request does not say. As
+// Timed indexes created_at, these suit large collections well
+var DefaultPageSort = bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// ErrPageOptions is returned by FindPage when given Sort, Skip or Limit
+// options, as pages are sorted, offset and limited by the PageRequest
+var ErrPageOptions = errors.New("sort, skip and limit of pages are set by the page request")
+
+// PageRequest asks for a page of documents: either at an offset, or
+// after or before (not both) a cursor returned along with an earlier page.
+// Cursors only work with the sort they were returned for
+type PageRequest struct {
+	Sort   bson.D // bson keys; _id is added as the last key, if missing
+	Limit  int64
+	Offset int64
+	After  string
+	Before string
+	Total  bool // count all the documents matching the filter
+}
+
+// PageInfo tells how to get to the pages around the one returned
+type PageInfo struct {
+	Next  string // cursor to the following page; empty on the last page
+	Prev  string // cursor to the preceding page; empty on the first page
+	Total *int64 // set, if asked for
+}
+
+type Page[T any] struct {
+	Items []T
+	PageInfo
+}
+
+// FindPage returns a page of the documents matching the filter, as a
+// slice of the model type. Paging by cursors (keyset pagination) reads
+// only the documents of the page, however deep into the collection.
+// The PageRequest alone decides the sort, offset and limit: passing the
+// Sort, Skip or Limit options fails with ErrPageOptions
+func FindPage(mc *MongoConn, model interface{}, filter interface{}, req PageRequest, opts ...QueryOption) (interface{}, PageInfo, error) {
+	info := PageInfo{}
+
+	qc := newQueryConfig(opts)
+	if len(qc.sort) > 0 || qc.skip > 0 || qc.limit > 0 {
+		return nil, info, ErrPageOptions
+	}
+	if req.After != "" && req.Before != "" {
+		return nil, info, errors.New("page request cannot be both after and before a cursor")
+	}
+
+	keys := pageSort(req.Sort)
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+
+	// Pages before a cursor are read backwards, and then turned around
+	backwards := req.Before != ""
+	cursor := req.After
+	if backwards {
+		cursor = req.Before
+	}
+
+	conditions := bson.A{}
+	if filter != nil {
+		conditions = append(conditions, filter)
+	}
+	if cursor != "" {
+		values, err := decodeCursor(cursor, keys)
+		if err != nil {
+			return nil, info, err
+		}
+		if err = checkCursorValues(model, keys, values); err != nil {
+			return nil, info, err
+		}
+		conditions = append(conditions, keysetFilter(keys, values, backwards))
+	}
+
+	var pageFilter interface{}
+	switch len(conditions) {
+	case 1:
+		pageFilter = conditions[0]
+	case 2:
+		pageFilter = bson.M{"$and": conditions}
+	}
+
+	proj, err := qc.projection(model)
+	if err != nil {
+		return nil, info, err
+	}
+
+	findOpts := options.Find().SetLimit(limit + 1)
+	if len(proj) > 0 {
+		if len(qc.selected) > 0 {
+			// Cursors are made of the sort keys
+			selected := proj.Map()
+			for _, e := range keys {
+				if _, found := selected[e.Key]; !found {
+					proj = append(proj, bson.E{Key: e.Key, Value: 1})
+				}
+			}
+		}
+		findOpts.SetProjection(proj)
+	}
+	if backwards {
+		findOpts.SetSort(reverseSort(keys))
+	} else {
+		findOpts.SetSort(keys)
+	}
+	if cursor == "" && req.Offset > 0 {
+		findOpts.SetSkip(req.Offset)
+	}
+
+	ctx, cancel := GetContext()
+	defer cancel()
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return nil, info, err
+	}
+	cur, err := coll.Find(ctx, qc.scope(model, pageFilter), findOpts)
+	if err != nil {
+		return nil, info, err
+	}
+	docs := []bson.Raw{}
+	if err = cur.All(ctx, &docs); err != nil {
+		return nil, info, err
+	}
+
+	more := int64(len(docs)) > limit
+	if more {
+		docs = docs[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+	}
+
+	list := reflect.ValueOf(newModelSlice(model)).Elem()
+	for _, doc := range docs {
+		out := newModel(model)
+		if err = bson.Unmarshal(doc, out); err != nil {
+			return nil, info, err
+		}
+		list = reflect.Append(list, reflect.ValueOf(out).Elem())
+	}
+
+	if len(docs) > 0 {
+		first, last := docs[0], docs[len(docs)-1]
+		hasNext, hasPrev := more, cursor != "" || req.Offset > 0
+		if backwards {
+			hasNext, hasPrev = true, more
+		}
+		if hasNext {
+			info.Next = encodeCursor(keys, last)
+		}
+		if hasPrev {
+			info.Prev = encodeCursor(keys, first)
+		}
+	}
+
+	if req.Total {
+		coll, err := mc.Collection(model)
+		if err != nil {
+			return nil, info, err
+		}
+		total, err := coll.CountDocuments(ctx, qc.scope(model, filter))
+		if err != nil {
+			return nil, info, err
+		}
+		info.Total = &total
+	}
+
+	return list.Interface(), info, nil
+}
+
+func pageSort(sort bson.D) bson.D {
+	if len(sort) == 0 {
+		return DefaultPageSort
+	}
+
+	keys := append(bson.D{}, sort...)
+	for _, e := range keys {
+		if e.Key == "_id" {
+			return keys
+		}
+	}
+	return append(keys, bson.E{Key: "_id", Value: sortOrder(keys[len(keys)-1].Value)})
+}
+
+func sortOrder(v interface{}) int {
+	if f, isNum := asFloat(v); isNum && f < 0 {
+		return -1
+	}
+	return 1
+}
+
+func reverseSort(keys bson.D) bson.D {
+	reversed := bson.D{}
+	for _, e := range keys {
+		reversed = append(reversed, bson.E{Key: e.Key, Value: -sortOrder(e.Value)})
+	}
+	return reversed
+}
+
+// keysetFilter matches the documents that come after the values of
+// the sort keys (or before them, going backwards), ie. those greater
+// on the first key, or equal on it and greater on the next key ...
+func keysetFilter(keys bson.D, values bson.A, backwards bool) bson.M {
+	or := bson.A{}
+	for i, e := range keys {
+		cond := bson.M{}
+		for j := 0; j < i; j++ {
+			cond[keys[j].Key] = values[j]
+		}
+		op := "$gt"
+		if (sortOrder(e.Value) < 0) != backwards {
+			op = "$lt"
+		}
+		cond[e.Key] = bson.M{op: values[i]}
+		or = append(or, cond)
+	}
+	return bson.M{"$or": or}
+}
+
+type pageCursor struct {
+	Keys   []string `bson:"k"`
+	Values bson.A   `bson:"v"`
+}
+
+func cursorKeys(keys bson.D) []string {
+	list := []string{}
+	for _, e := range keys {
+		if sortOrder(e.Value) < 0 {
+			list = append(list, "-"+e.Key)
+		} else {
+			list = append(list, e.Key)
+		}
+	}
+	return list
+}
+
+// encodeCursor captures the values of the sort keys of the
+// document, so that paging can carry on from the document
+func encodeCursor(keys bson.D, doc bson.Raw) string {
+	pc := pageCursor{Keys: cursorKeys(keys), Values: bson.A{}}
+	for _, e := range keys {
+		var val interface{}
+		if rv, err := doc.LookupErr(strings.Split(e.Key, ".")...); err == nil {
+			rv.Unmarshal(&val)
+		}
+		pc.Values = append(pc.Values, val)
+	}
+
+	raw, _ := bson.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(cursor string, keys bson.D) (bson.A, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	pc := pageCursor{}
+	if err = bson.Unmarshal(raw, &pc); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if strings.Join(pc.Keys, ",") != strings.Join(cursorKeys(keys), ",") || len(pc.Values) != len(keys) {
+		return nil, errors.New("page cursor does not match the sort")
+	}
+	return pc.Values, nil
+}
+
+// checkCursorValues checks the values of a cursor (which the client may
+// have made up) against the bson types of the sort keys, as the values go
+// into the query as they are. Whatever the keys, values cannot be
+// documents (which could hold query operators) or arrays
+func checkCursorValues(model interface{}, keys bson.D, values bson.A) error {
+	schema := JsonSchema(model)
+	for i, e := range keys {
+		if !cursorValueFits(fieldSchema(schema, e.Key), values[i]) {
+			return ErrInvalidCursor
+		}
+	}
+	return nil
+}
+
+// fieldSchema returns the schema of the field at the dotted
+// path, or nil if the schema does not describe the field
+func fieldSchema(schema bson.M, path string) bson.M {
+	for _, key := range strings.Split(path, ".") {
+		props, _ := schema["properties"].(bson.M)
+		if schema, _ = props[key].(bson.M); schema == nil {
+			return nil
+		}
+	}
+	return schema
+}
+
+func cursorValueFits(schema bson.M, val interface{}) bool {
+	bt := valueBsonType(val)
+	switch bt {
+	case "null":
+		// Documents missing the key
+		return true
+	case "", "object", "array":
+		return false
+	}
+
+	types, found := schema["bsonType"]
+	if !found {
+		return true
+	}
+	for _, t := range bsonTypes(types) {
+		if t == bt || isNumberType(t) && isNumberType(bt) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueBsonType returns the bson type of a decoded value
+func valueBsonType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.DateTime:
+		return "date"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.Binary:
+		return "binData"
+	case primitive.D, primitive.M:
+		return "object"
+	case primitive.A:
+		return "array"
+	}
+	return ""
+}
+
+func isNumberType(t interface{}) bool {
+	switch t {
+	case "int", "long", "double", "decimal", "number":
+		return true
+	}
+	return false
+}
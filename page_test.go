@@ -0,0 +1,120 @@
+package monk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPageSort(t *testing.T) {
+
+	assert.Equal(t, DefaultPageSort, pageSort(nil))
+
+	// _id breaks ties, in the order of the last key
+	assert.Equal(t,
+		bson.D{{Key: "age", Value: 1}, {Key: "name", Value: -1}, {Key: "_id", Value: -1}},
+		pageSort(bson.D{{Key: "age", Value: 1}, {Key: "name", Value: -1}}))
+
+	assert.Equal(t,
+		bson.D{{Key: "age", Value: -1}, {Key: "_id", Value: 1}},
+		reverseSort(bson.D{{Key: "age", Value: 1}, {Key: "_id", Value: int32(-1)}}))
+}
+
+func TestKeysetFilter(t *testing.T) {
+
+	keys := bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	values := bson.A{"2024", "x"}
+
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"created_at": bson.M{"$lt": "2024"}},
+		bson.M{"created_at": "2024", "_id": bson.M{"$lt": "x"}},
+	}}, keysetFilter(keys, values, false))
+
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"created_at": bson.M{"$gt": "2024"}},
+		bson.M{"created_at": "2024", "_id": bson.M{"$gt": "x"}},
+	}}, keysetFilter(keys, values, true))
+}
+
+func TestPageCursor(t *testing.T) {
+
+	keys := bson.D{{Key: "home.city", Value: 1}, {Key: "_id", Value: 1}}
+	doc, _ := bson.Marshal(bson.M{"_id": "a-1", "home": bson.M{"city": "Pune"}})
+
+	cursor := encodeCursor(keys, doc)
+	values, err := decodeCursor(cursor, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, bson.A{"Pune", "a-1"}, values)
+
+	// Cursors are tied to their sort
+	_, err = decodeCursor(cursor, bson.D{{Key: "_id", Value: 1}})
+	assert.NotNil(t, err)
+
+	_, err = decodeCursor("not a cursor", keys)
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestCursorValues(t *testing.T) {
+
+	keys := bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}
+	assert.Nil(t, checkCursorValues(crudTest{}, keys, bson.A{"abc", "ct-1"}))
+	assert.Nil(t, checkCursorValues(crudTest{}, keys, bson.A{nil, "ct-1"}))
+
+	// Values have to be of the types of the fields
+	assert.Equal(t, ErrInvalidCursor, checkCursorValues(crudTest{}, keys, bson.A{int32(1), "ct-1"}))
+
+	// And cannot be documents (eg. operators) or arrays, of any key
+	assert.Equal(t, ErrInvalidCursor, checkCursorValues(crudTest{}, keys, bson.A{bson.D{{Key: "$ne", Value: nil}}, "ct-1"}))
+	assert.Equal(t, ErrInvalidCursor, checkCursorValues(crudTest{}, bson.D{{Key: "other", Value: 1}}, bson.A{bson.A{"x"}}))
+	assert.Nil(t, checkCursorValues(crudTest{}, bson.D{{Key: "other", Value: 1}}, bson.A{int64(5)}))
+
+	// Nested fields, and numbers of any size
+	nested := bson.D{{Key: "count", Value: 1}, {Key: "address.city", Value: 1}}
+	assert.Nil(t, checkCursorValues(schemaTest{}, nested, bson.A{int64(3), "Pune"}))
+	assert.Equal(t, ErrInvalidCursor, checkCursorValues(schemaTest{}, nested, bson.A{"3", "Pune"}))
+}
+
+func TestFindPageOptions(t *testing.T) {
+
+	// The page request decides the sort, offset and limit
+	for _, opt := range []QueryOption{Sort(bson.D{{Key: "name", Value: 1}}), Skip(5), Limit(5)} {
+		_, _, err := FindPage(&testConnection, crudTest{}, nil, PageRequest{}, opt)
+		assert.Equal(t, ErrPageOptions, err)
+	}
+
+	// Pages are either after or before a cursor
+	_, _, err := FindPage(&testConnection, crudTest{}, nil, PageRequest{After: "a", Before: "b"})
+	assert.NotNil(t, err)
+}
+
+func TestFindPage(t *testing.T) {
+
+	for i := 0; i < 5; i++ {
+		_, err := Insert(&testConnection, crudTest{}, do.Map{"name": fmt.Sprintf("page-%d", i)})
+		assert.Nil(t, err)
+	}
+
+	req := PageRequest{Sort: bson.D{{Key: "name", Value: 1}}, Limit: 2, Total: true}
+	filter := bson.M{"name": bson.M{"$regex": "^page-"}}
+
+	out, info, err := FindPage(&testConnection, crudTest{}, filter, req)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(out.([]crudTest)))
+	assert.EqualValues(t, 5, *info.Total)
+	assert.Equal(t, "", info.Prev)
+
+	req.After, req.Total = info.Next, false
+	out, info, err = FindPage(&testConnection, crudTest{}, filter, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "page-2", out.([]crudTest)[0].Name)
+	assert.NotEqual(t, "", info.Prev)
+
+	req.After, req.Before = "", info.Prev
+	out, _, err = FindPage(&testConnection, crudTest{}, filter, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "page-0", out.([]crudTest)[0].Name)
+	assert.Equal(t, "page-1", out.([]crudTest)[1].Name)
+}
@@ -0,0 +1,272 @@
+package monk
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/outerjoin/do"
+)
+
+/*
+	VALIDATION PIPELINE
+
+	Validate runs the data through named stages, in order:
+
+	insertable  insert / update tags
+	defaults    default tag, upon insert
+	trim        trim tag
+	convert     inputs to the types of the fields (MongoStores only)
+	timestamps  created_at / updated_at of Timed MongoStores
+	auto        auto tag, upon insert
+	verify      verify tag
+
+	Secret fields are then hashed, if no stage found issues. Hashing is
+	not a stage, so that no pipeline can leave it out, nor run it before
+	the verify checks (which see the plain text).
+
+	Stages can be added, removed and reordered on Validation (used for
+	all the models), or on a pipeline of a model's own:
+
+	monk.Validation.InsertAfter(monk.StageVerify, monk.ValidationStage{
+		Name: "unique_email",
+		Run:  checkUniqueEmail,
+	})
+*/
+
+const (
+	StageInsertable = "insertable"
+	StageDefaults   = "defaults"
+	StageTrim       = "trim"
+	StageConvert    = "convert"
+	StageTimestamps = "timestamps"
+	StageAuto       = "auto"
+	StageVerify     = "verify"
+)
+
+// StageFunc works on the data (keyed by field keys) for the action,
+// changing it as need be, and returns the issues found with it. Issues
+// reference the fields by their dotted paths (eg. "address.city")
+type StageFunc func(modelType interface{}, action int, data do.Map) []do.ErrorReference
+
+type ValidationStage struct {
+	Name string
+	Run  StageFunc
+
+	// Skip the stage if earlier stages found issues
+	OnlyIfValid bool
+}
+
+type ValidationPipeline struct {
+	mu     sync.RWMutex
+	stages []ValidationStage
+}
+
+// The pipeline Validate runs for models that do not have one of their own
+var Validation = DefaultValidationPipeline()
+
+// Models that want a validation pipeline of their own, implement this
+type PipelineValidated interface {
+	ValidationPipeline() *ValidationPipeline
+}
+
+func validationPipelineOf(model interface{}) *ValidationPipeline {
+	if m, ok := model.(PipelineValidated); ok {
+		return m.ValidationPipeline()
+	} else if m, ok := newModel(model).(PipelineValidated); ok {
+		return m.ValidationPipeline()
+	}
+	return Validation
+}
+
+func NewValidationPipeline(stages ...ValidationStage) (*ValidationPipeline, error) {
+	p := &ValidationPipeline{}
+	for _, stage := range stages {
+		if err := p.Add(stage); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// DefaultValidationPipeline returns a new pipeline
+// of the stages Validate runs by default
+func DefaultValidationPipeline() *ValidationPipeline {
+	p, _ := NewValidationPipeline(
+		ValidationStage{Name: StageInsertable, Run: checkInsertableUpdatable},
+		ValidationStage{Name: StageDefaults, Run: provideDefualts},
+		ValidationStage{Name: StageTrim, Run: trimFields},
+		ValidationStage{Name: StageConvert, Run: mongoStoresOnly(convertFieldType)},
+		ValidationStage{Name: StageTimestamps, Run: populateTimedFields},
+		ValidationStage{Name: StageAuto, Run: populateAutoFields},
+		ValidationStage{Name: StageVerify, Run: verifyInputs},
+	)
+	return p
+}
+
+func mongoStoresOnly(fn StageFunc) StageFunc {
+	return func(modelType interface{}, action int, data do.Map) []do.ErrorReference {
+		if !do.TypeComposedOf(modelType, MongoStore{}) {
+			return nil
+		}
+		return fn(modelType, action, data)
+	}
+}
+
+// Stages returns the names of the stages, in the order they run
+func (p *ValidationPipeline) Stages() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := []string{}
+	for _, stage := range p.stages {
+		names = append(names, stage.Name)
+	}
+	return names
+}
+
+func (p *ValidationPipeline) index(name string) int {
+	for i, stage := range p.stages {
+		if stage.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert puts the stage at position i. Callers hold the lock
+func (p *ValidationPipeline) insert(i int, stage ValidationStage) error {
+	if stage.Name == "" || stage.Run == nil {
+		return fmt.Errorf("validation stage needs a name and a function")
+	}
+	if p.index(stage.Name) >= 0 {
+		return fmt.Errorf("validation stage '%s' already exists", stage.Name)
+	}
+
+	p.stages = append(p.stages, ValidationStage{})
+	copy(p.stages[i+1:], p.stages[i:])
+	p.stages[i] = stage
+	return nil
+}
+
+// Add appends the stage, to run after all the others
+func (p *ValidationPipeline) Add(stage ValidationStage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.insert(len(p.stages), stage)
+}
+
+// InsertBefore puts the stage right before the named one
+func (p *ValidationPipeline) InsertBefore(name string, stage ValidationStage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("unknown validation stage '%s'", name)
+	}
+	return p.insert(i, stage)
+}
+
+// InsertAfter puts the stage right after the named one
+func (p *ValidationPipeline) InsertAfter(name string, stage ValidationStage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("unknown validation stage '%s'", name)
+	}
+	return p.insert(i+1, stage)
+}
+
+// Remove drops the named stage
+func (p *ValidationPipeline) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("unknown validation stage '%s'", name)
+	}
+	p.stages = append(p.stages[:i], p.stages[i+1:]...)
+	return nil
+}
+
+// Reorder has the stages run in the given order, which
+// has to name every stage of the pipeline exactly once
+func (p *ValidationPipeline) Reorder(names ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(names) != len(p.stages) {
+		return fmt.Errorf("reorder needs all the %d validation stages, but got %d", len(p.stages), len(names))
+	}
+	ordered := []ValidationStage{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		i := p.index(name)
+		if i < 0 {
+			return fmt.Errorf("unknown validation stage '%s'", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("validation stage '%s' given more than once", name)
+		}
+		seen[name] = true
+		ordered = append(ordered, p.stages[i])
+	}
+	p.stages = ordered
+	return nil
+}
+
+// Run passes the data through the stages, in order. The issues found
+// are returned both by field (as Validate does) and as a list, in the
+// order they were found
+func (p *ValidationPipeline) Run(modelType interface{}, action int, data do.Map) (FieldErrors, []do.ErrorReference) {
+	p.mu.RLock()
+	stages := append([]ValidationStage{}, p.stages...)
+	p.mu.RUnlock()
+
+	errs := FieldErrors{}
+	refs := []do.ErrorReference{}
+	seen := map[do.ErrorReference]bool{}
+
+	for _, stage := range stages {
+		if stage.OnlyIfValid && len(refs) > 0 {
+			continue
+		}
+		for _, ref := range stage.Run(modelType, action, data) {
+			// Every stage walks the model, and so
+			// reports issues with its shape again
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			refs = append(refs, ref)
+			errs.Add(ref.Message, ref.Reference)
+		}
+	}
+	return errs, refs
+}
+
+// walkModel calls op for the fields of the model (see TraverseModel),
+// and returns the issues with the shape of the data it comes across
+func walkModel(modelType interface{}, data do.Map, op func(reflect.StructField, do.Map, ...string)) []do.ErrorReference {
+	walkErrs := FieldErrors{}
+	TraverseModel(modelType, data, walkErrs, op)
+
+	keys := []string{}
+	for key := range walkErrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	refs := []do.ErrorReference{}
+	for _, key := range keys {
+		for _, issue := range walkErrs[key] {
+			refs = append(refs, do.ErrorReference{Message: issue, Reference: key})
+		}
+	}
+	return refs
+}
@@ -0,0 +1,107 @@
+package monk
+
+import (
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+)
+
+type pipelineTest struct {
+	Name    string `json:"name" insert:"yes" verify:"len(2,)"`
+	Code    string `json:"code" default:" x1 "`
+	Address struct {
+		City string `json:"city" verify:"alpha"`
+	} `json:"address"`
+}
+
+type ownPipelineTest struct {
+	Name string `json:"name" verify:"len(2,)"`
+	Code string `json:"code" insert:"no"`
+	Pass string `json:"pass" secret:"bcrypt"`
+}
+
+var ownPipeline, _ = NewValidationPipeline(ValidationStage{Name: StageVerify, Run: verifyInputs})
+
+func (ownPipelineTest) ValidationPipeline() *ValidationPipeline {
+	return ownPipeline
+}
+
+func TestValidationPipeline(t *testing.T) {
+
+	p := DefaultValidationPipeline()
+	assert.Equal(t, []string{"insertable", "defaults", "trim", "convert", "timestamps", "auto", "verify"}, p.Stages())
+
+	// Both forms of the issues
+	errs, refs := p.Run(pipelineTest{}, INSERT, do.Map{"address": map[string]interface{}{"city": "pune1"}})
+	assert.Equal(t, []string{"field 'name' needs a value upon insertion"}, errs["name"])
+	assert.Equal(t, 1, len(errs["address.city"]))
+	assert.Equal(t, []do.ErrorReference{
+		{Message: "field 'name' needs a value upon insertion", Reference: "name"},
+		{Message: errs["address.city"][0], Reference: "address.city"},
+	}, refs)
+
+	// Issues with the shape of the data are reported once
+	errs, refs = p.Run(pipelineTest{}, INSERT, do.Map{"name": "ab", "address": "pune"})
+	assert.Equal(t, []string{"field 'address' expected dict, but found literal"}, errs["address"])
+	assert.Equal(t, 1, len(refs))
+
+	// Defaults are trimmed, unless trim runs first
+	data := do.Map{"name": "ab"}
+	p.Run(pipelineTest{}, INSERT, data)
+	assert.Equal(t, "x1", data["code"])
+
+	assert.Nil(t, p.Reorder("insertable", "trim", "defaults", "convert", "timestamps", "auto", "verify"))
+	data = do.Map{"name": "ab"}
+	p.Run(pipelineTest{}, INSERT, data)
+	assert.Equal(t, " x1 ", data["code"])
+
+	assert.NotNil(t, p.Reorder("insertable", "trim"))
+	assert.NotNil(t, p.Reorder("insertable", "trim", "trim", "convert", "timestamps", "auto", "verify"))
+
+	// Removing and adding stages
+	assert.Nil(t, p.Remove(StageInsertable))
+	assert.NotNil(t, p.Remove(StageInsertable))
+	errs, _ = p.Run(pipelineTest{}, INSERT, do.Map{})
+	assert.Equal(t, 0, len(errs))
+
+	taken := ValidationStage{Name: "no_x", Run: func(modelType interface{}, action int, data do.Map) []do.ErrorReference {
+		if data["code"] == "x1" {
+			return []do.ErrorReference{{Message: "x1 is taken", Reference: "code"}}
+		}
+		return nil
+	}}
+	assert.Nil(t, p.InsertAfter(StageTrim, taken))
+	assert.NotNil(t, p.Add(taken))
+	assert.NotNil(t, p.InsertBefore("missing", ValidationStage{Name: "y", Run: taken.Run}))
+	assert.Equal(t, []string{"trim", "no_x", "defaults", "convert", "timestamps", "auto", "verify"}, p.Stages())
+
+	errs, _ = p.Run(pipelineTest{}, INSERT, do.Map{"code": " x1 "})
+	assert.Equal(t, []string{"x1 is taken"}, errs["code"])
+}
+
+func TestOwnValidationPipeline(t *testing.T) {
+
+	// The model's own pipeline only verifies
+	ok, issues := Validate(ownPipelineTest{}, INSERT, do.Map{"name": "a"})
+	assert.False(t, ok)
+	assert.Equal(t, 1, len(issues["name"]))
+
+	// Nor checks the insert tags
+	ok, _ = Validate(&ownPipelineTest{}, INSERT, do.Map{"name": "abc", "code": "x"})
+	assert.True(t, ok)
+
+	// Secrets are hashed all the same, but only if the data is fine
+	data := do.Map{"name": "abc", "pass": "s3cret"}
+	ok, _ = Validate(ownPipelineTest{}, INSERT, data)
+	assert.True(t, ok)
+	assert.True(t, secretMatches(data["pass"].(string), "s3cret"))
+
+	data = do.Map{"name": "a", "pass": "s3cret"}
+	Validate(ownPipelineTest{}, INSERT, data)
+	assert.Equal(t, "s3cret", data["pass"])
+
+	// Other models run Validation
+	ok, _ = Validate(pipelineTest{}, UPDATE, do.Map{"name": "a"})
+	assert.False(t, ok)
+}
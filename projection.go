@@ -0,0 +1,198 @@
+package monk
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+	PROJECTION
+
+	hidden:"yes"         field is never read back, nor serialized
+	                     (nor are secret fields, see VerifySecret)
+	read:"admin|support" field is read back and serialized only for
+	                     readers having one of the roles (see Roles)
+
+	Select(fields...) reads back just the given fields, regardless
+	of the tags (eg. for checking a password)
+
+	The tags are followed by the reads of the package, and by ToJSON
+	and JSONView. Plain json.Marshal knows nothing of them, and so
+	serializes hidden, secret and read-restricted fields like any
+	other: serialize models with ToJSON, or tag such fields json:"-"
+*/
+
+// Select reads back only the given fields (json paths), overriding
+// the hidden and read tags of the model
+func Select(fields ...string) QueryOption {
+	return func(qc *queryConfig) {
+		qc.selected = append(qc.selected, fields...)
+	}
+}
+
+// Roles tells the roles of the reader, which decide the
+// fields (tagged read:"...") that are read back
+func Roles(roles ...string) QueryOption {
+	return func(qc *queryConfig) {
+		qc.roles = append(qc.roles, roles...)
+	}
+}
+
+// projection returns the fields to read back: the selected fields, or else
+// all but those that are hidden from the reader. Unknown selected fields are
+// returned as FieldErrors
+func (qc *queryConfig) projection(model interface{}) (bson.D, error) {
+	proj := bson.D{}
+
+	if len(qc.selected) > 0 {
+		errs := FieldErrors{}
+		for _, field := range qc.selected {
+			bsonPath, _, found := resolveField(do.TypeOf(model), strings.Split(field, "."))
+			if !found {
+				errs.Add("unknown field", field)
+				continue
+			}
+			proj = append(proj, bson.E{Key: bsonPath, Value: 1})
+		}
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return proj, nil
+	}
+
+	hiddenPaths(do.TypeOf(model), "", qc.roles, func(path string) {
+		proj = append(proj, bson.E{Key: path, Value: 0})
+	})
+	return proj, nil
+}
+
+// hiddenPaths calls found with the bson path of every field
+// (including those of sub-documents) hidden from the roles
+func hiddenPaths(t reflect.Type, prefix string, roles []string, found func(string)) {
+	t = do.TypeDereference(elemType(t))
+	if t.Kind() != reflect.Struct || do.TypeIsTime(t) || t == objectIDType {
+		return
+	}
+
+	walkFields(t, func(sf reflect.StructField) {
+		if !sf.IsExported() {
+			return
+		}
+		path := BsonKey(sf)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if hiddenFrom(sf, roles) {
+			found(path)
+			return
+		}
+		hiddenPaths(sf.Type, path, roles, found)
+	})
+}
+
+// hiddenFrom tells if the field is not to be shown to the roles
+func hiddenFrom(sf reflect.StructField, roles []string) bool {
+	if sf.Tag.Get("hidden") == "yes" || sf.Tag.Get("secret") != "" {
+		return true
+	}
+
+	allowed := sf.Tag.Get("read")
+	if allowed == "" {
+		return false
+	}
+	for _, role := range strings.Split(allowed, "|") {
+		for _, r := range roles {
+			if role != "" && role == r {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// JSONView returns the value as it is to be serialized to JSON for the
+// roles (as generic maps and slices), leaving out the fields that are
+// hidden from them (which json.Marshal of the value would not)
+func JSONView(v interface{}, roles ...string) (interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var view interface{}
+	if err = json.Unmarshal(raw, &view); err != nil {
+		return nil, err
+	}
+
+	if v != nil {
+		stripHidden(reflect.TypeOf(v), view, roles)
+	}
+	return view, nil
+}
+
+// ToJSON serializes the value to JSON for the roles (see JSONView)
+func ToJSON(v interface{}, roles ...string) ([]byte, error) {
+	view, err := JSONView(v, roles...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(view)
+}
+
+func stripHidden(t reflect.Type, view interface{}, roles []string) {
+	t = do.TypeDereference(t)
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if list, ok := view.([]interface{}); ok {
+			for _, item := range list {
+				stripHidden(t.Elem(), item, roles)
+			}
+		}
+		return
+	case reflect.Map:
+		if m, ok := view.(map[string]interface{}); ok {
+			for _, item := range m {
+				stripHidden(t.Elem(), item, roles)
+			}
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	m, ok := view.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" && sf.Anonymous {
+			// encoding/json promotes the fields of embedded structs
+			stripHidden(sf.Type, m, roles)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if hiddenFrom(sf, roles) {
+			delete(m, name)
+		} else if val, found := m[name]; found {
+			stripHidden(sf.Type, val, roles)
+		}
+	}
+}
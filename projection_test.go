@@ -0,0 +1,103 @@
+package monk
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type projectionTest struct {
+	Timed   `bson:",inline"`
+	ID      string `bson:"_id" json:"uuid"`
+	Name    string `bson:"name" json:"name"`
+	Pin     string `bson:"pin" json:"pin" hidden:"yes"`
+	Salary  int    `bson:"salary" json:"salary" read:"hr|admin"`
+	Profile struct {
+		Phone string `bson:"phone" json:"phone" read:"support"`
+		City  string `bson:"city" json:"city"`
+	} `bson:"profile" json:"profile"`
+	Notes []struct {
+		Text    string `bson:"text" json:"text"`
+		Private string `bson:"private" json:"private" hidden:"yes"`
+	} `bson:"notes" json:"notes"`
+}
+
+func TestProjection(t *testing.T) {
+
+	proj, err := newQueryConfig(nil).projection(projectionTest{})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "pin", Value: 0},
+		{Key: "salary", Value: 0},
+		{Key: "profile.phone", Value: 0},
+		{Key: "notes.private", Value: 0},
+	}, proj)
+
+	// Roles reveal the fields they may read
+	proj, err = newQueryConfig([]QueryOption{Roles("admin", "support")}).projection(projectionTest{})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{{Key: "pin", Value: 0}, {Key: "notes.private", Value: 0}}, proj)
+
+	// Select overrides the tags
+	proj, err = newQueryConfig([]QueryOption{Select("uuid", "pin", "created_at")}).projection(projectionTest{})
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{{Key: "_id", Value: 1}, {Key: "pin", Value: 1}, {Key: "created_at", Value: 1}}, proj)
+
+	_, err = newQueryConfig([]QueryOption{Select("pni")}).projection(projectionTest{})
+	assert.Equal(t, FieldErrors{"pni": {"unknown field"}}, err)
+
+	// User passwords are never read back
+	proj, _ = newQueryConfig(nil).projection(User{})
+	assert.Equal(t, bson.D{{Key: "password", Value: 0}}, proj)
+}
+
+func TestToJSON(t *testing.T) {
+
+	p := projectionTest{ID: "p-1", Name: "Joe", Pin: "1234", Salary: 10}
+	p.Profile.Phone = "555"
+	p.Notes = append(p.Notes, struct {
+		Text    string `bson:"text" json:"text"`
+		Private string `bson:"private" json:"private" hidden:"yes"`
+	}{"hi", "secret"})
+
+	out, err := ToJSON(&p)
+	assert.Nil(t, err)
+
+	view := map[string]interface{}{}
+	json.Unmarshal(out, &view)
+	assert.Equal(t, "Joe", view["name"])
+	assert.NotContains(t, view, "pin")
+	assert.NotContains(t, view, "salary")
+	assert.Contains(t, view, "created_at")
+	assert.Equal(t, map[string]interface{}{"city": ""}, view["profile"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"text": "hi"}}, view["notes"])
+
+	list, err := JSONView([]projectionTest{p}, "hr")
+	assert.Nil(t, err)
+	assert.EqualValues(t, 10, list.([]interface{})[0].(map[string]interface{})["salary"])
+}
+
+func TestPlainJSON(t *testing.T) {
+
+	// json.Marshal does not follow the tags: hidden,
+	// secret and restricted fields are all serialized
+	p := projectionTest{ID: "p-1", Pin: "1234", Salary: 10}
+	out, err := json.Marshal(p)
+	assert.Nil(t, err)
+	view := map[string]interface{}{}
+	json.Unmarshal(out, &view)
+	assert.Equal(t, "1234", view["pin"])
+	assert.EqualValues(t, 10, view["salary"])
+
+	u := User{UUID: "u-1", Password: "$2a$10$hash"}
+	out, err = json.Marshal(u)
+	assert.Nil(t, err)
+	assert.Contains(t, string(out), `"password":"$2a$10$hash"`)
+
+	// Unlike ToJSON
+	out, err = ToJSON(u)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(out), "password")
+}
@@ -0,0 +1,174 @@
+package monk
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Query is what a URL query string asks for (see ParseQuery)
+type Query struct {
+	Filter bson.D
+	Sort   bson.D
+	Offset int64
+	Limit  int64
+}
+
+// Options returns the query's sort and pagination, to
+// be passed along with its filter to Find
+func (q Query) Options() []QueryOption {
+	opts := []QueryOption{}
+	if len(q.Sort) > 0 {
+		opts = append(opts, Sort(q.Sort))
+	}
+	if q.Offset > 0 {
+		opts = append(opts, Skip(q.Offset))
+	}
+	if q.Limit > 0 {
+		opts = append(opts, Limit(q.Limit))
+	}
+	return opts
+}
+
+// Operators that can be suffixed to fields in query strings
+var queryOperators = map[string]func(f *Filter, path string, values []string) *Filter{
+	"eq":  func(f *Filter, path string, values []string) *Filter { return f.Eq(path, values[0]) },
+	"ne":  func(f *Filter, path string, values []string) *Filter { return f.Ne(path, values[0]) },
+	"gt":  func(f *Filter, path string, values []string) *Filter { return f.Gt(path, values[0]) },
+	"gte": func(f *Filter, path string, values []string) *Filter { return f.Gte(path, values[0]) },
+	"lt":  func(f *Filter, path string, values []string) *Filter { return f.Lt(path, values[0]) },
+	"lte": func(f *Filter, path string, values []string) *Filter { return f.Lte(path, values[0]) },
+	"in":  func(f *Filter, path string, values []string) *Filter { return f.In(path, csvValues(values)...) },
+	"nin": func(f *Filter, path string, values []string) *Filter { return f.Nin(path, csvValues(values)...) },
+	"exists": func(f *Filter, path string, values []string) *Filter {
+		exists, _ := do.ParseType(values[0], reflect.TypeOf(true))
+		return f.Exists(path, exists.(bool))
+	},
+}
+
+// ParseQuery turns a URL query string into a Query on the model, eg.
+//
+//	?status=active&created_at__gte=2024-01-01&sort=-created_at&limit=20
+//
+// Fields are given by their json keys (dot separated, for sub-documents),
+// optionally suffixed with __eq, __ne, __gt, __gte, __lt, __lte, __in,
+// __nin (comma separated values) or __exists. Only fields tagged with
+// filter:"yes" can be filtered and sorted on. The reserved params are
+// sort (comma separated fields, prefixed with "-" for descending order),
+// limit and offset. Bad params are returned as FieldErrors
+func ParseQuery(model interface{}, values url.Values) (Query, error) {
+	q := Query{}
+	errs := FieldErrors{}
+	filter := NewFilter(model)
+
+	// Params are worked on in order, so that the filter is stable
+	params := []string{}
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		vals := values[param]
+		if len(vals) == 0 {
+			continue
+		}
+
+		switch param {
+		case "sort":
+			q.Sort = parseQuerySort(model, vals, errs)
+			continue
+		case "limit", "offset":
+			n, err := strconv.ParseInt(vals[0], 10, 64)
+			if err != nil || n < 0 {
+				errs.Add("must be a whole number", param)
+				continue
+			}
+			if param == "limit" {
+				q.Limit = n
+			} else {
+				q.Offset = n
+			}
+			continue
+		}
+
+		path, op := param, "eq"
+		if at := strings.LastIndex(param, "__"); at > 0 {
+			path, op = param[:at], param[at+2:]
+		}
+		apply, found := queryOperators[op]
+		if !found {
+			errs.Add("unknown operator "+op, param)
+			continue
+		}
+		if op == "eq" && len(vals) > 1 {
+			apply = queryOperators["in"]
+		}
+		if !filterable(model, path) {
+			errs.Add("cannot be filtered on", param)
+			continue
+		}
+
+		cond, err := apply(NewFilter(model), path, vals).D()
+		if err != nil {
+			for _, issues := range err.(FieldErrors) {
+				for _, issue := range issues {
+					errs.Add(issue, param)
+				}
+			}
+			continue
+		}
+		for _, e := range cond {
+			for _, c := range e.Value.(bson.D) {
+				filter.add(e.Key, c.Key, c.Value)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return Query{}, errs
+	}
+
+	q.Filter = filter.doc
+	return q, nil
+}
+
+func parseQuerySort(model interface{}, vals []string, errs FieldErrors) bson.D {
+	keys := bson.D{}
+	for _, field := range csvValues(vals) {
+		path, order := field.(string), 1
+		if strings.HasPrefix(path, "-") {
+			path, order = path[1:], -1
+		}
+		if !filterable(model, path) {
+			errs.Add("cannot be sorted on "+path, "sort")
+			continue
+		}
+		bsonPath, _, _ := resolveField(do.TypeOf(model), strings.Split(path, "."))
+		keys = append(keys, bson.E{Key: bsonPath, Value: order})
+	}
+	return keys
+}
+
+// filterable tells if the field at the json path is tagged filter:"yes"
+func filterable(model interface{}, path string) bool {
+	_, sf, found := resolveField(do.TypeOf(model), strings.Split(path, "."))
+	return found && sf.Tag.Get("filter") == "yes"
+}
+
+func csvValues(vals []string) []interface{} {
+	list := []interface{}{}
+	for _, val := range vals {
+		for _, part := range strings.Split(val, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+	}
+	return list
+}
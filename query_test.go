@@ -0,0 +1,65 @@
+package monk
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type queryTest struct {
+	Timed  `bson:",inline"`
+	ID     string `bson:"_id" json:"uuid"`
+	Status string `bson:"status" json:"status" filter:"yes"`
+	Age    int    `bson:"age" json:"age" filter:"yes"`
+	Secret string `bson:"secret" json:"secret"`
+	Home   struct {
+		City string `bson:"city" json:"city" filter:"yes"`
+	} `bson:"home" json:"home"`
+}
+
+func TestParseQuery(t *testing.T) {
+
+	values, _ := url.ParseQuery("status=active&created_at__gte=2024-01-01&age__in=18,21&home.city=Pune&sort=-created_at,age&limit=20&offset=40")
+	q, err := ParseQuery(queryTest{}, values)
+
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "age", Value: bson.D{{Key: "$in", Value: bson.A{18, 21}}}},
+		{Key: "created_at", Value: bson.D{{Key: "$gte", Value: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}},
+		{Key: "home.city", Value: bson.D{{Key: "$eq", Value: "Pune"}}},
+		{Key: "status", Value: bson.D{{Key: "$eq", Value: "active"}}},
+	}, q.Filter)
+	assert.Equal(t, bson.D{{Key: "created_at", Value: -1}, {Key: "age", Value: 1}}, q.Sort)
+	assert.EqualValues(t, 20, q.Limit)
+	assert.EqualValues(t, 40, q.Offset)
+	assert.Equal(t, 3, len(q.Options()))
+
+	// Repeated params match any of the values, and
+	// ranges on the same field are put together
+	values, _ = url.ParseQuery("status=a&status=b&age__gt=1&age__lte=9")
+	q, err = ParseQuery(queryTest{}, values)
+	assert.Nil(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "age", Value: bson.D{{Key: "$gt", Value: 1}, {Key: "$lte", Value: 9}}},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: bson.A{"a", "b"}}}},
+	}, q.Filter)
+	assert.Empty(t, q.Options())
+}
+
+func TestParseQueryErrors(t *testing.T) {
+
+	values, _ := url.ParseQuery("secret=x&nope=1&age__gt=old&age__like=1&sort=secret&limit=ten")
+	_, err := ParseQuery(queryTest{}, values)
+
+	errs, ok := err.(FieldErrors)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"cannot be filtered on"}, errs["secret"])
+	assert.Equal(t, []string{"cannot be filtered on"}, errs["nope"])
+	assert.Equal(t, 1, len(errs["age__gt"]))
+	assert.Equal(t, []string{"unknown operator like"}, errs["age__like"])
+	assert.Equal(t, []string{"cannot be sorted on secret"}, errs["sort"])
+	assert.Equal(t, []string{"must be a whole number"}, errs["limit"])
+}
@@ -0,0 +1,99 @@
+package monk
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Repo gives typed access to the collection that stores model T,
+// so that callers get back T and []T instead of decoding documents
+// by hand. T must be a struct type (not a pointer)
+type Repo[T any] struct {
+	Conn *MongoConn
+}
+
+// NewRepo returns an error if T is not a struct type
+func NewRepo[T any](mc *MongoConn) (*Repo[T], error) {
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repo model must be a struct type, not %s", t)
+	}
+	return &Repo[T]{Conn: mc}, nil
+}
+
+// model returns the zero value of T, used to describe the model to
+// the untyped functions of the package
+func (r *Repo[T]) model() T {
+	var t T
+	return t
+}
+
+func (r *Repo[T]) CollectionName() string {
+	return CollectionName(r.model())
+}
+
+func (r *Repo[T]) Collection() (*mongo.Collection, error) {
+	return r.Conn.Collection(r.model())
+}
+
+// Insert validates and inserts the data, and returns the inserted document
+func (r *Repo[T]) Insert(data do.Map) (T, error) {
+	out, err := InsertSelect(r.Conn, r.model(), data)
+	if err != nil {
+		return r.model(), err
+	}
+	return *out.(*T), nil
+}
+
+func (r *Repo[T]) FindByID(id interface{}, opts ...QueryOption) (T, error) {
+	return r.FindOne(bson.M{"_id": id}, opts...)
+}
+
+func (r *Repo[T]) FindOne(filter interface{}, opts ...QueryOption) (T, error) {
+	out, err := FindOne(r.Conn, r.model(), filter, opts...)
+	if err != nil {
+		return r.model(), err
+	}
+	return *out.(*T), nil
+}
+
+func (r *Repo[T]) Find(filter interface{}, opts ...QueryOption) ([]T, error) {
+	out, err := Find(r.Conn, r.model(), filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out.([]T), nil
+}
+
+func (r *Repo[T]) Update(filter interface{}, data do.Map, opts ...QueryOption) (int64, error) {
+	return Update(r.Conn, r.model(), filter, data, opts...)
+}
+
+// UpdateSelect updates a single document and returns it as it is after the update
+func (r *Repo[T]) UpdateSelect(filter interface{}, data do.Map, opts ...QueryOption) (T, error) {
+	out, err := UpdateSelect(r.Conn, r.model(), filter, data, opts...)
+	if err != nil {
+		return r.model(), err
+	}
+	return *out.(*T), nil
+}
+
+func (r *Repo[T]) Delete(filter interface{}, opts ...QueryOption) (int64, error) {
+	return Delete(r.Conn, r.model(), filter, opts...)
+}
+
+func (r *Repo[T]) Count(filter interface{}, opts ...QueryOption) (int64, error) {
+	return Count(r.Conn, r.model(), filter, opts...)
+}
+
+// Page returns a page of the documents matching the filter (see FindPage)
+func (r *Repo[T]) Page(filter interface{}, req PageRequest, opts ...QueryOption) (Page[T], error) {
+	out, info, err := FindPage(r.Conn, r.model(), filter, req, opts...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return Page[T]{Items: out.([]T), PageInfo: info}, nil
+}
@@ -0,0 +1,50 @@
+package monk
+
+import (
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRepoCollectionName(t *testing.T) {
+	repo, err := NewRepo[AbraKaDabra](&testConnection)
+	assert.Nil(t, err)
+	assert.Equal(t, "abra_ka_dabra", repo.CollectionName())
+
+	override, err := NewRepo[AbraKaDabraOverride](&testConnection)
+	assert.Nil(t, err)
+	assert.Equal(t, "i_AM_different", override.CollectionName())
+}
+
+func TestNewRepo(t *testing.T) {
+
+	// Models are structs, not pointers to them
+	_, err := NewRepo[*crudTest](&testConnection)
+	assert.NotNil(t, err)
+	_, err = NewRepo[map[string]interface{}](&testConnection)
+	assert.NotNil(t, err)
+}
+
+func TestRepo(t *testing.T) {
+
+	repo, err := NewRepo[crudTest](&testConnection)
+	assert.Nil(t, err)
+
+	inserted, err := repo.Insert(do.Map{"name": "repo", "email": "repo@def.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, "repo", inserted.Name)
+
+	found, err := repo.FindByID(inserted.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, inserted, found)
+
+	list, err := repo.Find(bson.M{"name": "repo"})
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	count, err := repo.Delete(bson.M{"_id": inserted.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -2,135 +2,221 @@ package monk
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
+	"github.com/outerjoin/do"
 	"github.com/rightjoin/rutl/conv"
-	"github.com/rightjoin/rutl/refl"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func CreateCollection(mc *MongoConn, types ...interface{}) {
+// SchemaError is an issue in setting up the collection of a model
+type SchemaError struct {
+	Collection string
+	Step       string // validation | indexes | records
+	Err        error  // IndexErrors, for the indexes step
+}
 
-	// Setup Schema Validations
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("collection %s: %s: %s", e.Collection, e.Step, e.Err)
+}
 
-	// Setup Indexes (normal and unique)
-	for _, t := range types {
-		CreateIndexes(mc, t)
-	}
+func (e SchemaError) Unwrap() error {
+	return e.Err
+}
 
-	// Create initial records
-	for _, t := range types {
-		InsertInitalRecords(mc, t)
-	}
+type SchemaErrors []SchemaError
 
+func (errs SchemaErrors) Error() string {
+	list := []string{}
+	for _, e := range errs {
+		list = append(list, e.Error())
+	}
+	return strings.Join(list, "; ")
 }
 
-// InsertInitalRecords invokes "PrePopulate" and inserts the returned
-// values into the database
-func InsertInitalRecords(mc *MongoConn, model interface{}) {
+// CreateCollection sets up the collections of the given models: their
+// schema validations, indexes and initial records. It carries on past
+// failures, and returns all of them as SchemaErrors
+func CreateCollection(ctx context.Context, mc *MongoConn, types ...interface{}) error {
+	errs := SchemaErrors{}
 
-	ot := reflect.TypeOf(model)
-	ov := reflect.ValueOf(model)
-
-	// Dereference
-	if ot.Kind() == reflect.Ptr {
-		ot = ot.Elem()
-		ov = ov.Elem()
+	steps := []struct {
+		name string
+		run  func(context.Context, *MongoConn, interface{}) error
+	}{
+		{"validation", ApplySchemaValidation}, // Setup Schema Validations
+		{"indexes", CreateIndexes},            // Setup Indexes (normal and unique)
+		{"records", InsertInitalRecords},      // Create initial records
 	}
 
-	// If "CollectionName" method exists, call it
-	if _, ok := ot.MethodByName("PrePopulate"); ok {
-		output := ov.MethodByName("PrePopulate").Call([]reflect.Value{})
-		records, ok := output[0].Interface().([]interface{})
-		if !ok {
-			return
-		}
-		for _, rec := range records {
-			rt := reflect.TypeOf(rec)
-			if rt.Kind() == reflect.Map {
-				// TODO
-				// Invoke Insert
-			} else {
-				// TODO
-				// json encode and decode into map
-				// Invoke Insert
+	for _, step := range steps {
+		for _, t := range types {
+			if err := ctx.Err(); err != nil {
+				return append(errs, SchemaError{CollectionName(t), step.name, err})
+			}
+			if err := step.run(ctx, mc, t); err != nil {
+				errs = append(errs, SchemaError{CollectionName(t), step.name, err})
 			}
 		}
 	}
 
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// InsertInitalRecords upserts the records returned by "PrePopulate"
+// (see SeedRecords), and logs what was done with them
+func InsertInitalRecords(ctx context.Context, mc *MongoConn, model interface{}) error {
+
+	report, err := SeedRecords(ctx, mc, model)
+	if err != nil {
+		return err
+	}
+
+	if total := len(report.Created) + len(report.Updated) + len(report.Unchanged); total > 0 {
+		log.Info().
+			Str("collection", CollectionName(model)).
+			Int("created", len(report.Created)).
+			Int("updated", len(report.Updated)).
+			Int("unchanged", len(report.Unchanged)).
+			Msg("seeded initial records")
+	}
+	return nil
 }
 
 // index|unique:"true|true:-1"
 // index|unique:"idx_name"
 // index|unique:"idx_name(field1,field2)"
-func CreateIndexes(mc *MongoConn, model interface{}) {
-
-	indexes := mc.Collection(model).Indexes()
-	ctx, _ := context.WithTimeout(context.Background(), 15*time.Second)
+// index:"true:2dsphere" | index:"idx_name(title:text,body:text);weights(title:5)"
+// index:"true;ttl(3600)" | index:"true;sparse;collation(en:2)"
+// index:"true;partial({\"active\":1})"
+// index:"idx_name@1" + index:"idx_name:-1@2" (on another field)
+//
+// CreateIndexes returns IndexErrors, listing the conflicting
+// declarations and the indexes that could not be created
+func CreateIndexes(ctx context.Context, mc *MongoConn, model interface{}) error {
 
-	indexesToCreate := GetAllIndexes(model)
-	for _, idx := range indexesToCreate {
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return err
+	}
+	indexes := coll.Indexes()
 
-		m := bson.D{}
-		for i := range idx.Fields {
-			m = append(m, bson.E{Key: idx.Fields[i], Value: idx.Order[i]})
-		}
+	errs := IndexErrors{}
+	indexesToCreate, err := GetAllIndexes(model)
+	if err != nil {
+		errs = append(errs, err.(IndexErrors)...)
+	}
 
-		newIndex := mongo.IndexModel{
-			Keys: m,
-			Options: &options.IndexOptions{
-				Unique: &idx.Unique,
-				Name:   &idx.Name,
-			},
+	for _, idx := range indexesToCreate {
+		if _, err := indexes.CreateOne(ctx, idx.Model()); err != nil {
+			errs = append(errs, IndexError{Index: idx.Name, Err: err})
 		}
+	}
 
-		data, err := indexes.CreateOne(ctx, newIndex)
-		if err != nil {
-			log.Error().
-				Err(err).
-				Str("Return value", data).
-				Msg("Counld not create index")
-		}
+	if len(errs) > 0 {
+		return errs
 	}
+	return nil
 }
 
-func GetAllIndexes(model interface{}) []MonkIndex {
+// GetAllIndexes returns the indexes declared on the fields of the model.
+// Declarations that share a name (across fields) are merged into a single
+// compound index; conflicting declarations and invalid index options are
+// reported as IndexErrors, and the indexes concerned are left out
+func GetAllIndexes(model interface{}) ([]MonkIndex, error) {
 	var list = []MonkIndex{}
-	fields := refl.NestedFields(model)
+	errs := IndexErrors{}
+	fields := indexableFields(do.TypeOf(model))
 	for i := 0; i < len(fields); i++ {
-		list = append(list, GetFieldIndexes(fields[i])...)
+		indexes, invalid := fieldIndexes(fields[i])
+		list = append(list, indexes...)
+		errs = append(errs, invalid...)
 	}
 
-	return list
+	merged, err := mergeIndexes(list)
+	if err != nil {
+		errs = append(errs, err.(IndexErrors)...)
+	}
+	if len(errs) == 0 {
+		return merged, nil
+	}
+
+	// Indexes are not created without the options asked for
+	valid := []MonkIndex{}
+	for _, idx := range merged {
+		if !errs.has(idx.Name) {
+			valid = append(valid, idx)
+		}
+	}
+	return valid, errs
 }
 
+// indexableFields lists the fields of the struct and of its nested
+// structs. A nested struct that is itself tagged for indexing (eg. a
+// Coordinate with a 2dsphere index) is listed rather than descended into
+func indexableFields(t reflect.Type) []reflect.StructField {
+	t = do.TypeDereference(t)
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tagged := sf.Tag.Get("index") != "" || sf.Tag.Get("unique") != ""
+		if sf.Type.Kind() == reflect.Struct && !do.TypeIsTime(sf.Type) && !tagged {
+			fields = append(fields, indexableFields(sf.Type)...)
+		} else {
+			fields = append(fields, sf)
+		}
+	}
+	return fields
+}
+
+// GetFieldIndexes returns the indexes declared on the field. Invalid
+// index options are left out (GetAllIndexes reports them)
 func GetFieldIndexes(f reflect.StructField) []MonkIndex {
+	list, _ := fieldIndexes(f)
+	return list
+}
+
+func fieldIndexes(f reflect.StructField) ([]MonkIndex, IndexErrors) {
 	var list = []MonkIndex{}
+	errs := IndexErrors{}
 	indexAll := f.Tag.Get("index")
 	uniqueAll := f.Tag.Get("unique")
 	name := FieldKey(f)
 
+	add := func(tagData string, unique bool) {
+		idx, err := parseIndex(tagData, name, unique)
+		if err != nil {
+			errs = append(errs, IndexError{Index: idx.Name, Err: err})
+		}
+		list = append(list, idx)
+	}
+
 	if indexAll != "" {
 		indexes := strings.Split(indexAll, "|")
 		for _, index := range indexes {
-			list = append(list, GetIndex(index, name, false))
+			add(index, false)
 		}
 	}
 
 	if uniqueAll != "" {
 		uniques := strings.Split(uniqueAll, "|")
 		for _, unique := range uniques {
-			list = append(list, GetIndex(unique, name, true))
+			add(unique, true)
 		}
 	}
 
-	return list
+	return list, errs
 }
 
 type MonkIndex struct {
@@ -138,6 +224,57 @@ type MonkIndex struct {
 	Unique bool
 	Fields []string
 	Order  []int
+	Types  []string // text | 2dsphere | 2d | hashed, used (if set) in place of order
+
+	// Position of the field within a compound index that is declared
+	// across fields, eg. index:"idx_name@2" (0: in order of declaration)
+	Position int
+
+	Sparse    bool
+	TTL       *int32           // expireAfterSeconds
+	Partial   bson.D           // partialFilterExpression
+	Weights   map[string]int32 // for text indexes
+	Collation *IndexCollation
+}
+
+type IndexCollation struct {
+	Locale   string `bson:"locale"`
+	Strength int    `bson:"strength"`
+}
+
+// key returns the order (or type) of the i-th field of the index
+func (idx MonkIndex) key(i int) interface{} {
+	if i < len(idx.Types) && idx.Types[i] != "" {
+		return idx.Types[i]
+	}
+	return idx.Order[i]
+}
+
+// Model returns the index, as expected by the mongo driver
+func (idx MonkIndex) Model() mongo.IndexModel {
+	keys := bson.D{}
+	for i := range idx.Fields {
+		keys = append(keys, bson.E{Key: idx.Fields[i], Value: idx.key(i)})
+	}
+
+	opts := options.Index().SetName(idx.Name).SetUnique(idx.Unique)
+	if idx.Sparse {
+		opts.SetSparse(true)
+	}
+	if idx.TTL != nil {
+		opts.SetExpireAfterSeconds(*idx.TTL)
+	}
+	if len(idx.Partial) > 0 {
+		opts.SetPartialFilterExpression(idx.Partial)
+	}
+	if len(idx.Weights) > 0 {
+		opts.SetWeights(idx.Weights)
+	}
+	if idx.Collation != nil {
+		opts.SetCollation(&options.Collation{Locale: idx.Collation.Locale, Strength: idx.Collation.Strength})
+	}
+
+	return mongo.IndexModel{Keys: keys, Options: opts}
 }
 
 // Given a struct, or address of a struct, get the
@@ -164,34 +301,54 @@ func CollectionName(model interface{}) string {
 	return strings.TrimSpace(conv.CaseSnake(t.Name()))
 }
 
+// GetIndex parses the index declared by the tag on the field. Invalid
+// index options are left out (GetAllIndexes reports them)
 func GetIndex(tagData, fieldName string, unique bool) MonkIndex {
-	idx := MonkIndex{Unique: unique, Fields: []string{}, Order: []int{}}
+	idx, _ := parseIndex(tagData, fieldName, unique)
+	return idx
+}
+
+// parseIndex parses the index declared by the tag, and returns
+// an error if any of its options is invalid
+func parseIndex(tagData, fieldName string, unique bool) (MonkIndex, error) {
+	idx := MonkIndex{Unique: unique, Fields: []string{}, Order: []int{}, Types: []string{}}
+
+	// Options follow the index definition, separated by ;
+	parts := splitIndexTag(tagData)
+	tagData = parts[0]
+	var invalid error
+	for _, opt := range parts[1:] {
+		if err := idx.setOption(opt); err != nil && invalid == nil {
+			invalid = fmt.Errorf("invalid option '%s': %w", strings.TrimSpace(opt), err)
+		}
+	}
+
+	// Position within a compound index: idx_name@2 | idx_name:-1@2
+	if at := strings.LastIndex(tagData, "@"); at != -1 && !strings.Contains(tagData, "(") {
+		idx.Position = conv.IntOr(tagData[at+1:], 0)
+		tagData = tagData[0:at]
+	}
+
 	lbrace := strings.Index(tagData, "(")
 
-	if ok, _ := regexp.MatchString("true(:(-|\\+)\\d+)?", tagData); ok {
-		// index:"true" | index:"true:+1"
+	if ok, _ := regexp.MatchString("^true(:.+)?$", tagData); ok {
+		// index:"true" | index:"true:+1" | index:"true:2dsphere"
 		split := strings.Split(tagData, ":")
+		idx.Name = "idx_" + fieldName
 		if len(split) == 1 {
-			idx.Name = "idx_" + fieldName
-			idx.Fields = append(idx.Fields, fieldName)
-			idx.Order = append(idx.Order, 1)
+			idx.addField(fieldName, "")
 		} else {
-			idx.Name = "idx_" + fieldName
-			idx.Fields = append(idx.Fields, fieldName)
-			idx.Order = append(idx.Order, conv.IntOr(split[1], 1))
+			idx.addField(fieldName, split[1])
 		}
 	} else if lbrace == -1 {
 		// index:"idx_name" | index:"idx_name:+1"
 		split := strings.Split(tagData, ":")
 		idx.Name = tagData
 		if len(split) == 1 {
-			idx.Name = tagData
-			idx.Fields = append(idx.Fields, fieldName)
-			idx.Order = append(idx.Order, 1)
+			idx.addField(fieldName, "")
 		} else {
 			idx.Name = split[0]
-			idx.Fields = append(idx.Fields, fieldName)
-			idx.Order = append(idx.Order, conv.IntOr(split[1], 1))
+			idx.addField(fieldName, split[1])
 		}
 	} else {
 		// index:"idx_name(field1,field2)" | index:"idx_name(field1:+1,field2:-1)"
@@ -202,13 +359,100 @@ func GetIndex(tagData, fieldName string, unique bool) MonkIndex {
 		for i := range flds {
 			split := strings.Split(flds[i], ":")
 			if len(split) == 1 {
-				idx.Fields = append(idx.Fields, strings.TrimSpace(split[0]))
-				idx.Order = append(idx.Order, 1)
+				idx.addField(strings.TrimSpace(split[0]), "")
 			} else {
-				idx.Fields = append(idx.Fields, strings.TrimSpace(split[0]))
-				idx.Order = append(idx.Order, conv.IntOr(split[1], 1))
+				idx.addField(strings.TrimSpace(split[0]), split[1])
 			}
 		}
 	}
-	return idx
+	return idx, invalid
+}
+
+var indexTypes = map[string]bool{"text": true, "2dsphere": true, "2d": true, "hashed": true}
+
+// addField adds a field to the index, in the given order
+// (+1 by default) or of the given type (text, 2dsphere, ...)
+func (idx *MonkIndex) addField(field, order string) {
+	order = strings.TrimSpace(order)
+	idx.Fields = append(idx.Fields, field)
+	if indexTypes[order] {
+		idx.Order = append(idx.Order, 1)
+		idx.Types = append(idx.Types, order)
+	} else {
+		idx.Order = append(idx.Order, conv.IntOr(order, 1))
+		idx.Types = append(idx.Types, "")
+	}
+}
+
+// setOption applies one of the index options:
+//
+// sparse
+// ttl(seconds)
+// partial({"field": value})
+// weights(field1:10,field2:5)
+// collation(locale) | collation(locale:strength)
+func (idx *MonkIndex) setOption(opt string) error {
+	opt = strings.TrimSpace(opt)
+	name, arg := opt, ""
+	if lbrace := strings.Index(opt, "("); lbrace != -1 && strings.HasSuffix(opt, ")") {
+		name, arg = opt[0:lbrace], strings.TrimSpace(opt[lbrace+1:len(opt)-1])
+	}
+
+	switch name {
+	case "sparse":
+		idx.Sparse = true
+	case "ttl":
+		secs, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			return fmt.Errorf("ttl expects seconds, but received %s", arg)
+		}
+		ttl := int32(secs)
+		idx.TTL = &ttl
+	case "partial":
+		filter := bson.D{}
+		if err := bson.UnmarshalExtJSON([]byte(arg), false, &filter); err != nil {
+			return fmt.Errorf("partial expects a json filter: %w", err)
+		}
+		idx.Partial = filter
+	case "weights":
+		idx.Weights = map[string]int32{}
+		for _, pair := range strings.Split(arg, ",") {
+			split := strings.Split(pair, ":")
+			if len(split) != 2 {
+				return fmt.Errorf("weights expects field:weight pairs, but received %s", pair)
+			}
+			idx.Weights[strings.TrimSpace(split[0])] = int32(conv.IntOr(strings.TrimSpace(split[1]), 1))
+		}
+	case "collation":
+		split := strings.Split(arg, ":")
+		idx.Collation = &IndexCollation{Locale: strings.TrimSpace(split[0])}
+		if len(split) > 1 {
+			idx.Collation.Strength = conv.IntOr(strings.TrimSpace(split[1]), 0)
+		}
+	default:
+		return errors.New("unsupported index option")
+	}
+
+	return nil
+}
+
+// splitIndexTag splits the index definition from its options (on ;)
+// leaving alone any ; within braces
+func splitIndexTag(tagData string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i, ch := range tagData {
+		switch ch {
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case ';':
+			if depth == 0 {
+				parts = append(parts, tagData[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tagData[start:])
 }
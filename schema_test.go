@@ -38,7 +38,8 @@ type AbcIndex struct {
 
 func TestGetAllIndexes(t *testing.T) {
 
-	var list = GetAllIndexes(AbcIndex{})
+	list, err := GetAllIndexes(AbcIndex{})
+	assert.Nil(t, err)
 	// assert.Len(t, list, 6)
 
 	// Field1
@@ -112,7 +113,8 @@ type DefUniqueIndex struct {
 
 func TestGetAllUniqueIndexes(t *testing.T) {
 
-	var list = GetAllIndexes(DefUniqueIndex{})
+	list, err := GetAllIndexes(DefUniqueIndex{})
+	assert.Nil(t, err)
 	// assert.Len(t, list, 6)
 
 	// Field1
@@ -178,18 +180,21 @@ func TestGetAllUniqueIndexes(t *testing.T) {
 func TestCreateIndexes(t *testing.T) {
 
 	// Check no indexes
-	indexes := testConnection.Collection(DefUniqueIndex{}).Indexes()
+	coll, err := testConnection.Collection(DefUniqueIndex{})
+	assert.Nil(t, err)
+	indexes := coll.Indexes()
 	ctx, cancel := GetContext()
 	defer cancel()
 	cur, _ := indexes.List(ctx)
 
 	var result []bson.M
-	err := cur.All(context.TODO(), &result)
+	err = cur.All(context.TODO(), &result)
 	assert.Nil(t, err)
 	assert.Equal(t, 0, len(result))
 
 	// Create the indexes
-	CreateIndexes(&testConnection, DefUniqueIndex{})
+	err = CreateIndexes(ctx, &testConnection, DefUniqueIndex{})
+	assert.Nil(t, err)
 
 	// Check the count of indexes
 	cur, _ = indexes.List(ctx)
@@ -197,3 +202,132 @@ func TestCreateIndexes(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, 7+1 /*for _id*/, len(result))
 }
+
+type OptionsIndex struct {
+	Field1 string     `index:"true;ttl(3600)"`
+	Field2 Coordinate `index:"true:2dsphere;sparse"`
+	Field3 string     `index:"idx_search(field3:text, field4:text);weights(field3:10)"`
+	Field4 string     `unique:"true;partial({\"deleted\":0});collation(en:2)"`
+	Field5 string     `index:"idx_hash:hashed"`
+}
+
+func TestGetIndexOptions(t *testing.T) {
+
+	list, err := GetAllIndexes(OptionsIndex{})
+	assert.Nil(t, err)
+
+	// ttl
+	assert.Equal(t, "idx_field1", list[0].Name)
+	assert.Equal(t, int32(3600), *list[0].TTL)
+	assert.Equal(t, bson.D{{Key: "field1", Value: 1}}, list[0].Model().Keys)
+
+	// 2dsphere, sparse
+	assert.Equal(t, "idx_field2", list[1].Name)
+	assert.True(t, list[1].Sparse)
+	assert.Equal(t, bson.D{{Key: "field2", Value: "2dsphere"}}, list[1].Model().Keys)
+
+	// text with weights
+	assert.Equal(t, "idx_search", list[2].Name)
+	assert.Equal(t, []string{"text", "text"}, list[2].Types)
+	assert.Equal(t, map[string]int32{"field3": 10}, list[2].Weights)
+
+	// partial filter, collation
+	assert.Equal(t, true, list[3].Unique)
+	assert.Equal(t, bson.D{{Key: "deleted", Value: int32(0)}}, list[3].Partial)
+	assert.Equal(t, &IndexCollation{Locale: "en", Strength: 2}, list[3].Collation)
+
+	// hashed
+	assert.Equal(t, "idx_hash", list[4].Name)
+	assert.Equal(t, bson.D{{Key: "field5", Value: "hashed"}}, list[4].Model().Keys)
+}
+
+type CompoundIndex struct {
+	Field1 string `index:"idx_compound@2"`
+	Field2 string `index:"idx_compound:-1@1"`
+	Field3 string `index:"idx_compound"`
+	Field4 string `unique:"idx_full(field4,field5)"`
+	Field5 string `unique:"idx_full(field4,field5)"`
+}
+
+func TestGetAllIndexesMerged(t *testing.T) {
+
+	list, err := GetAllIndexes(CompoundIndex{})
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+
+	// Declarations across fields make one index,
+	// ordered by their positions
+	assert.Equal(t, "idx_compound", list[0].Name)
+	assert.Equal(t, []string{"field2", "field1", "field3"}, list[0].Fields)
+	assert.Equal(t, []int{-1, 1, 1}, list[0].Order)
+
+	// Repeated declarations are the same index
+	assert.Equal(t, "idx_full", list[1].Name)
+	assert.Equal(t, true, list[1].Unique)
+	assert.Equal(t, []string{"field4", "field5"}, list[1].Fields)
+}
+
+type ConflictingIndex struct {
+	Field1 string `index:"idx_a"`
+	Field2 string `unique:"idx_a"`
+	Field3 string `index:"idx_b@1"`
+	Field4 string `index:"idx_b@1"`
+	Field5 string `index:"idx_c"`
+}
+
+func TestGetAllIndexesConflicts(t *testing.T) {
+
+	list, err := GetAllIndexes(ConflictingIndex{})
+	errs, ok := err.(IndexErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "idx_a", errs[0].Index)
+	assert.Equal(t, "idx_b", errs[1].Index)
+
+	// Indexes without conflicts are still returned
+	assert.Len(t, list, 1)
+	assert.Equal(t, "idx_c", list[0].Name)
+}
+
+type InvalidOptionIndex struct {
+	Field1 string `index:"true;tll(3600)"`
+	Field2 string `unique:"idx_b;ttl(an hour)"`
+	Field3 string `index:"idx_c;sparse"`
+}
+
+func TestGetAllIndexesInvalidOptions(t *testing.T) {
+
+	list, err := GetAllIndexes(InvalidOptionIndex{})
+	errs, ok := err.(IndexErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "idx_field1", errs[0].Index)
+	assert.Contains(t, errs[0].Error(), "tll(3600)")
+	assert.Equal(t, "idx_b", errs[1].Index)
+
+	// Indexes are not created without their options
+	assert.Len(t, list, 1)
+	assert.Equal(t, "idx_c", list[0].Name)
+	assert.True(t, list[0].Sparse)
+}
+
+func TestSchemaSetupErrors(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Conflicts and failures are reported per index
+	err := CreateIndexes(ctx, &testConnection, ConflictingIndex{})
+	errs, ok := err.(IndexErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 3)
+	assert.Equal(t, "idx_c", errs[2].Index)
+	assert.ErrorIs(t, errs[2], context.Canceled)
+
+	// and per model
+	err = CreateCollection(ctx, &testConnection, ConflictingIndex{})
+	schemaErrs, ok := err.(SchemaErrors)
+	assert.True(t, ok)
+	assert.Equal(t, "conflicting_index", schemaErrs[0].Collection)
+	assert.ErrorIs(t, schemaErrs[0], context.Canceled)
+}
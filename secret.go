@@ -0,0 +1,136 @@
+package monk
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*
+	SECRETS
+
+	secret:"bcrypt" | secret:"argon2"
+
+	Secret fields are hashed by Validate upon insert and update (after
+	the verify checks, which see the plain text), are never trimmed, and
+	are never read back (unless asked for by Select). Hashes carry their
+	algorithm, so VerifySecret works even if the tag changes later on
+*/
+
+var BcryptCost = bcrypt.DefaultCost
+
+// Argon2id parameters, as recommended by RFC 9106
+var Argon2Params = struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen int
+}{1, 64 * 1024, 4, 32, 16}
+
+// hashSecret hashes the plain text with the algorithm
+// named in the secret tag
+func hashSecret(algo string, plaintext string) (string, error) {
+	switch algo {
+	case "bcrypt":
+		hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), BcryptCost)
+		return string(hash), err
+	case "argon2":
+		p := Argon2Params
+		salt := make([]byte, p.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(plaintext), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Time, p.Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	return "", fmt.Errorf("unknown secret hashing algorithm '%s'", algo)
+}
+
+// secretMatches tells if the plain text hashes to the given hash
+func secretMatches(hash string, plaintext string) bool {
+
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plaintext)) == nil
+	}
+
+	// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(plaintext), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// VerifySecret tells if the plain text matches the hash held by the secret
+// field (json key) of the model's value. The hash has to have been read back
+// explicitly, as secret fields are not read back otherwise:
+//
+//	user, err := repo.FindOne(filter, monk.Select("uuid", "password"))
+//	ok, err := monk.VerifySecret(user, "password", plaintext)
+func VerifySecret(model interface{}, field string, plaintext string) (bool, error) {
+	rv := reflect.ValueOf(model)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return false, errors.New("model cannot be nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return false, fmt.Errorf("cannot verify secrets of %T", model)
+	}
+
+	_, sf, found := resolveField(rv.Type(), []string{field})
+	if !found {
+		return false, fmt.Errorf("unknown field '%s'", field)
+	}
+	if sf.Tag.Get("secret") == "" {
+		return false, fmt.Errorf("field '%s' is not a secret", field)
+	}
+
+	hash, isStr := rv.FieldByName(sf.Name).Interface().(string)
+	if !isStr || hash == "" {
+		return false, fmt.Errorf("field '%s' holds no secret; was it read back?", field)
+	}
+
+	return secretMatches(hash, plaintext), nil
+}
+
+// secretFields returns the hashing algorithms of the
+// secret fields of the model, by their field keys
+func secretFields(modelType interface{}) map[string]string {
+	secrets := map[string]string{}
+	walkFields(modelType, func(sf reflect.StructField) {
+		if algo := sf.Tag.Get("secret"); algo != "" {
+			secrets[FieldKey(sf)] = algo
+		}
+	})
+	return secrets
+}
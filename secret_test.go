@@ -0,0 +1,79 @@
+package monk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type secretTest struct {
+	Name string `json:"name"`
+	Pass string `json:"pass" secret:"bcrypt" verify:"rex(^.{4,}$)"`
+	Pin  string `json:"pin" secret:"argon2"`
+}
+
+func TestHashSecret(t *testing.T) {
+
+	for _, algo := range []string{"bcrypt", "argon2"} {
+		hash, err := hashSecret(algo, "s3cret")
+		assert.Nil(t, err)
+		assert.NotEqual(t, "s3cret", hash)
+		assert.True(t, secretMatches(hash, "s3cret"), algo)
+		assert.False(t, secretMatches(hash, "s3cret "), algo)
+	}
+
+	_, err := hashSecret("md5", "s3cret")
+	assert.NotNil(t, err)
+	assert.False(t, secretMatches("$argon2id$garbage", "s3cret"))
+}
+
+func TestValidateSecrets(t *testing.T) {
+
+	data := do.Map{"name": " joe ", "pass": " pa55 ", "pin": "1234"}
+	ok, issues := Validate(secretTest{}, INSERT, data)
+	assert.True(t, ok, issues)
+	assert.Equal(t, "joe", data["name"])
+
+	// Hashed, and not trimmed before hashing
+	assert.True(t, strings.HasPrefix(data["pass"].(string), "$2a$"))
+	assert.True(t, strings.HasPrefix(data["pin"].(string), "$argon2id$"))
+	assert.True(t, secretMatches(data["pass"].(string), " pa55 "))
+
+	// Verify checks see the plain text
+	data = do.Map{"pass": "abc"}
+	ok, _ = Validate(secretTest{}, UPDATE, data)
+	assert.False(t, ok)
+	assert.Equal(t, "abc", data["pass"])
+
+	// Secrets are never read back
+	proj, _ := newQueryConfig(nil).projection(secretTest{})
+	assert.Equal(t, bson.D{{Key: "pass", Value: 0}, {Key: "pin", Value: 0}}, proj)
+}
+
+func TestVerifySecret(t *testing.T) {
+
+	hash, _ := hashSecret("bcrypt", "hunter2")
+	user := User{Username: "joe", Password: hash}
+
+	ok, err := VerifySecret(user, "password", "hunter2")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = VerifySecret(&user, "password", "hunter3")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = VerifySecret(user, "username", "joe")
+	assert.NotNil(t, err)
+	_, err = VerifySecret(User{}, "password", "hunter2")
+	assert.NotNil(t, err)
+	_, err = VerifySecret(user, "nope", "hunter2")
+	assert.NotNil(t, err)
+
+	// Seeding does not rehash secrets that have not changed
+	changes := seedChanges(User{}, do.Map{"uuid": "u", "password": "hunter2"}, bson.M{"_id": "u", "password": hash}, []string{"uuid"})
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,245 @@
+package monk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/outerjoin/do"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Models that have initial records (see PrePopulate) implement this,
+// so that the records can be matched to the ones already in the
+// database, and seeding can be run over and over again
+type NaturallyKeyed interface {
+	NaturalKey() []string // field keys, as in the data given to Insert
+}
+
+func naturalKeyOf(model interface{}) []string {
+	if m, ok := model.(NaturallyKeyed); ok {
+		return m.NaturalKey()
+	} else if m, ok := newModel(model).(NaturallyKeyed); ok {
+		return m.NaturalKey()
+	}
+	return []string{"uuid"}
+}
+
+// SeedReport lists the natural keys of the initial records, by
+// what seeding did with them
+type SeedReport struct {
+	Created   []bson.M
+	Updated   []bson.M
+	Unchanged []bson.M
+}
+
+// SeedRecords invokes "PrePopulate" and upserts the returned records (maps,
+// or values of the model) by the model's natural key: missing records are
+// inserted, records that differ are updated, and the rest are left alone.
+//
+// Fields of values of the model that hold zero values are taken as not set.
+// To seed a zero value (eg. false over a stored true), use a map record,
+// or a pointer field
+func SeedRecords(ctx context.Context, mc *MongoConn, model interface{}) (SeedReport, error) {
+	report := SeedReport{}
+
+	ov := reflect.ValueOf(model)
+	if ov.Kind() == reflect.Ptr {
+		ov = ov.Elem()
+	}
+
+	method := ov.MethodByName("PrePopulate")
+	if !method.IsValid() {
+		return report, nil
+	}
+	records, ok := method.Call([]reflect.Value{})[0].Interface().([]interface{})
+	if !ok {
+		return report, errors.New("PrePopulate must return []interface{}")
+	}
+
+	coll, err := mc.Collection(model)
+	if err != nil {
+		return report, err
+	}
+
+	keys := naturalKeyOf(model)
+	for i, rec := range records {
+		data, err := seedData(model, rec)
+		if err != nil {
+			return report, fmt.Errorf("record %d: %w", i, err)
+		}
+
+		naturalKey := do.Map{}
+		for _, k := range keys {
+			val, found := data[k]
+			if !found {
+				return report, fmt.Errorf("record %d: natural key %s is missing", i, k)
+			}
+			naturalKey[k] = val
+		}
+		filter := toDocument(model, naturalKey)
+
+		existing := bson.M{}
+		err = coll.FindOne(ctx, filter).Decode(&existing)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			if _, err = insert(ctx, mc, model, data); err != nil {
+				return report, fmt.Errorf("record %d: %w", i, err)
+			}
+			report.Created = append(report.Created, filter)
+			continue
+		case err != nil:
+			return report, fmt.Errorf("record %d: %w", i, err)
+		}
+
+		changes := seedChanges(model, data, existing, keys)
+		if len(changes) == 0 {
+			report.Unchanged = append(report.Unchanged, filter)
+			continue
+		}
+		if isVersioned(model) {
+			changes["version"] = existing["version"]
+		}
+		if _, err = update(ctx, mc, model, filter, changes, []QueryOption{IncludeDeleted}); err != nil {
+			return report, fmt.Errorf("record %d: %w", i, err)
+		}
+		report.Updated = append(report.Updated, filter)
+	}
+
+	return report, nil
+}
+
+// seedData turns an initial record into data for Insert. Values of the
+// model are marshalled, leaving out the fields that are not set (zero
+// values, and nil pointers; pointers to zero values are kept)
+func seedData(model interface{}, rec interface{}) (do.Map, error) {
+
+	if m, isMap := asMap(rec); isMap {
+		data := do.Map{}
+		for k, v := range m {
+			data[k] = v
+		}
+		return data, nil
+	}
+
+	rv := reflect.ValueOf(rec)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot seed a %T", rec)
+	}
+
+	raw, err := bson.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	dropZeroFields(rv, doc)
+
+	return fromDocument(model, doc), nil
+}
+
+func dropZeroFields(rv reflect.Value, doc bson.M) {
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && isNestedStruct(sf) {
+			if inline := rv.Field(i); inline.Kind() == reflect.Struct {
+				dropZeroFields(inline, doc)
+			}
+			continue
+		}
+		if rv.Field(i).IsZero() {
+			delete(doc, BsonKey(sf))
+		}
+	}
+}
+
+// seedChanges returns the values of the record that differ from
+// the ones stored in the database, other than the natural key
+func seedChanges(model interface{}, data do.Map, existing bson.M, keys []string) do.Map {
+	skip := map[string]bool{}
+	for _, k := range keys {
+		skip[k] = true
+	}
+
+	// Compare the values as they would be stored (trimmed, converted,
+	// ...). Secrets are left in plain text, as the pipeline does not
+	// hash them
+	validated := do.Map{}
+	for k, v := range data {
+		validated[k] = v
+	}
+	validationPipelineOf(model).Run(model, UPDATE, validated)
+
+	changes := do.Map{}
+	stored := fromDocument(model, existing)
+	secrets := secretFields(model)
+	for k, v := range data {
+		if skip[k] {
+			continue
+		}
+		if _, isSecret := secrets[k]; isSecret {
+			// Secrets are stored hashed
+			plaintext, _ := validated[k].(string)
+			hash, _ := stored[k].(string)
+			if !secretMatches(hash, plaintext) {
+				changes[k] = v
+			}
+			continue
+		}
+		if !sameValue(validated[k], stored[k]) {
+			changes[k] = v
+		}
+	}
+	return changes
+}
+
+// sameValue compares values by their bson encoding, as the
+// values read back from the database may be of other types
+// (eg. an int is read back as int32 or int64)
+func sameValue(a, b interface{}) bool {
+	if x, isNum := asFloat(a); isNum {
+		y, isNum := asFloat(b)
+		return isNum && x == y
+	}
+
+	am, aIsMap := asMap(a)
+	bm, bIsMap := asMap(b)
+	if aIsMap && bIsMap {
+		if len(am) != len(bm) {
+			return false
+		}
+		for k, v := range am {
+			if w, found := bm[k]; !found || !sameValue(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	ab, errA := bson.Marshal(bson.M{"v": a})
+	bb, errB := bson.Marshal(bson.M{"v": b})
+	return errA == nil && errB == nil && string(ab) == string(bb)
+}
+
+func asFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
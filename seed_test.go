@@ -0,0 +1,100 @@
+package monk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type SeedRole struct {
+	MongoStore `bson:",inline"`
+	UUID       string `bson:"_id" json:"uuid" auto:"uuid"`
+	Code       string `bson:"code" json:"code" insert:"yes"`
+	Title      string `bson:"title" json:"title"`
+	Level      int    `bson:"level" json:"level"`
+	Active     *bool  `bson:"active" json:"active"`
+}
+
+func (SeedRole) NaturalKey() []string {
+	return []string{"code"}
+}
+
+var seedRoleTitle = "Administrator"
+
+func (SeedRole) PrePopulate() []interface{} {
+	return []interface{}{
+		SeedRole{Code: "admin", Title: seedRoleTitle, Level: 9},
+		map[string]interface{}{"code": "guest", "title": "Guest"},
+	}
+}
+
+func TestSeedData(t *testing.T) {
+
+	data, err := seedData(SeedRole{}, SeedRole{Code: "admin", Level: 9})
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", data["code"])
+	assert.EqualValues(t, 9, data["level"])
+	_, found := data["title"]
+	assert.False(t, found) // not set
+	_, found = data["uuid"]
+	assert.False(t, found)
+
+	// Zero values are seeded through pointers
+	inactive := false
+	data, err = seedData(SeedRole{}, SeedRole{Code: "guest", Active: &inactive})
+	assert.Nil(t, err)
+	assert.Equal(t, false, data["active"])
+	_, found = data["level"]
+	assert.False(t, found)
+
+	_, err = seedData(SeedRole{}, 10)
+	assert.NotNil(t, err)
+}
+
+func TestSeedChanges(t *testing.T) {
+
+	existing := bson.M{"_id": "r-1", "code": "admin", "title": "Admin", "level": int64(9)}
+
+	changes := seedChanges(SeedRole{}, map[string]interface{}{"code": "admin", "title": "Admin", "level": 9}, existing, []string{"code"})
+	assert.Empty(t, changes)
+
+	// Values are compared as they would be stored
+	changes = seedChanges(SeedRole{}, map[string]interface{}{"code": "admin", "title": " Admin ", "level": "9"}, existing, []string{"code"})
+	assert.Empty(t, changes)
+
+	changes = seedChanges(SeedRole{}, map[string]interface{}{"code": "root", "title": "Root", "level": 9}, existing, []string{"code"})
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, "Root", changes["title"])
+
+	assert.Equal(t, []string{"code"}, naturalKeyOf(SeedRole{}))
+	assert.Equal(t, []string{"uuid"}, naturalKeyOf(User{}))
+}
+
+func TestSeedRecords(t *testing.T) {
+
+	ctx := context.Background()
+
+	report, err := SeedRecords(ctx, &testConnection, SeedRole{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Created))
+
+	// Running again changes nothing
+	report, err = SeedRecords(ctx, &testConnection, SeedRole{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Unchanged))
+
+	// Changed records are updated in place
+	seedRoleTitle = "Admin"
+	defer func() { seedRoleTitle = "Administrator" }()
+
+	report, err = SeedRecords(ctx, &testConnection, SeedRole{})
+	assert.Nil(t, err)
+	assert.Equal(t, []bson.M{{"code": "admin"}}, report.Updated)
+	assert.Equal(t, 1, len(report.Unchanged))
+
+	count, err := Count(&testConnection, SeedRole{}, bson.M{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, count)
+}
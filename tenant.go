@@ -0,0 +1,161 @@
+package monk
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+	TENANCY
+
+	Every Environment gets its own database (suffixed with the
+	environment's UUID), and every Instance within it gets its own
+	set of collections (suffixed with the instance's UUID)
+*/
+
+// Tenant is what an environment or instance UUID routes to
+type Tenant struct {
+	AccountUUID     string
+	EnvironmentUUID string
+	InstanceUUID    string // empty, if routed by environment
+
+	DBSuffix   string
+	CollSuffix string
+}
+
+// TenantRouter hands out MongoConns scoped to a tenant. It looks up
+// Environment and Instance records through Meta, and caches the result
+type TenantRouter struct {
+	Meta *MongoConn
+
+	mu    sync.RWMutex
+	cache map[string]Tenant // by kind and UUID (see cacheKey)
+}
+
+// Prefixes of the cache keys, which keep environments and instances
+// apart, so that a UUID of one kind is never routed as the other
+const (
+	envTenant      = "env:"
+	instanceTenant = "inst:"
+)
+
+func cacheKey(kind string, uuid string) string {
+	return kind + uuid
+}
+
+func NewTenantRouter(meta *MongoConn) *TenantRouter {
+	return &TenantRouter{Meta: meta, cache: map[string]Tenant{}}
+}
+
+// ForEnvironment returns a connection to the database of the environment
+func (tr *TenantRouter) ForEnvironment(envUUID string) (MongoConn, error) {
+	tenant, err := tr.cached(envTenant, envUUID, tr.lookupEnvironment)
+	if err != nil {
+		return MongoConn{}, err
+	}
+	return tenant.Conn()
+}
+
+// ForInstance returns a connection to the collections of the instance,
+// in the database of the environment that the instance belongs to
+func (tr *TenantRouter) ForInstance(instanceUUID string) (MongoConn, error) {
+	tenant, err := tr.cached(instanceTenant, instanceUUID, tr.lookupInstance)
+	if err != nil {
+		return MongoConn{}, err
+	}
+	return tenant.Conn()
+}
+
+// Forget drops the cached routing of the environment or instance,
+// so that it is looked up afresh upon next use. Forgetting an
+// environment forgets its instances as well
+func (tr *TenantRouter) Forget(uuid string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	delete(tr.cache, cacheKey(envTenant, uuid))
+	delete(tr.cache, cacheKey(instanceTenant, uuid))
+	for key, tenant := range tr.cache {
+		if tenant.InstanceUUID != "" && tenant.EnvironmentUUID == uuid {
+			delete(tr.cache, key)
+		}
+	}
+}
+
+func (tr *TenantRouter) cached(kind string, uuid string, lookup func(string) (Tenant, error)) (Tenant, error) {
+	key := cacheKey(kind, uuid)
+
+	tr.mu.RLock()
+	tenant, found := tr.cache[key]
+	tr.mu.RUnlock()
+	if found {
+		return tenant, nil
+	}
+
+	tenant, err := lookup(uuid)
+	if err != nil {
+		return tenant, err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.cache == nil {
+		tr.cache = map[string]Tenant{}
+	}
+	tr.cache[key] = tenant
+	return tenant, nil
+}
+
+func (tr *TenantRouter) lookupEnvironment(envUUID string) (Tenant, error) {
+	repo, err := NewRepo[Environment](tr.Meta)
+	if err != nil {
+		return Tenant{}, err
+	}
+	env, err := repo.FindByID(envUUID)
+	if err != nil {
+		return Tenant{}, fmt.Errorf("environment %s not found: %w", envUUID, err)
+	}
+
+	return Tenant{
+		AccountUUID:     env.AccountUUID,
+		EnvironmentUUID: env.UUID,
+		DBSuffix:        env.UUID,
+	}, nil
+}
+
+func (tr *TenantRouter) lookupInstance(instanceUUID string) (Tenant, error) {
+	repo, err := NewRepo[Instance](tr.Meta)
+	if err != nil {
+		return Tenant{}, err
+	}
+	inst, err := repo.FindByID(instanceUUID)
+	if err != nil {
+		return Tenant{}, fmt.Errorf("instance %s not found: %w", instanceUUID, err)
+	}
+
+	// The instance must belong to a live environment
+	tenant, err := tr.cached(envTenant, inst.EnvironmentUUID, tr.lookupEnvironment)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if tenant.AccountUUID != inst.AccountUUID {
+		return Tenant{}, fmt.Errorf("instance %s does not belong to the account of environment %s", inst.UUID, inst.EnvironmentUUID)
+	}
+
+	tenant.InstanceUUID = inst.UUID
+	tenant.CollSuffix = inst.UUID
+	return tenant, nil
+}
+
+// Conn returns a connection to the tenant's database (as configured
+// for its environment), scoped with the tenant's suffixes
+func (t Tenant) Conn() (MongoConn, error) {
+	mc, err := NewMongoConnToDB(t.EnvironmentUUID)
+	if err != nil {
+		return mc, err
+	}
+
+	mc.DBSuffix = t.DBSuffix
+	mc.CollSuffix = t.CollSuffix
+	return mc, nil
+}
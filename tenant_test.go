@@ -0,0 +1,75 @@
+package monk
+
+import (
+	"testing"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantConn(t *testing.T) {
+	defer resetConfig()
+
+	SetConfigResolver(func(envUUID string) (Config, error) {
+		return Config{ConnStr: "mongodb://127.0.0.1:27017/", DB: "tenants", DBSuffix: "ignored"}, nil
+	})
+
+	tr := NewTenantRouter(&testConnection)
+	tr.cache["inst:inst-1"] = Tenant{EnvironmentUUID: "env-1", InstanceUUID: "inst-1", DBSuffix: "env-1", CollSuffix: "inst-1"}
+
+	mc, err := tr.ForInstance("inst-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "tenants", mc.DB)
+	assert.Equal(t, "env-1", mc.DBSuffix)
+	assert.Equal(t, "inst-1", mc.CollSuffix)
+}
+
+func TestTenantForget(t *testing.T) {
+
+	tr := NewTenantRouter(&testConnection)
+	tr.cache["env:env-1"] = Tenant{EnvironmentUUID: "env-1", DBSuffix: "env-1"}
+	tr.cache["inst:inst-1"] = Tenant{EnvironmentUUID: "env-1", InstanceUUID: "inst-1", DBSuffix: "env-1", CollSuffix: "inst-1"}
+	tr.cache["inst:inst-2"] = Tenant{EnvironmentUUID: "env-2", InstanceUUID: "inst-2", DBSuffix: "env-2", CollSuffix: "inst-2"}
+
+	// Forgetting an environment forgets its instances
+	tr.Forget("env-1")
+	assert.NotContains(t, tr.cache, "env:env-1")
+	assert.NotContains(t, tr.cache, "inst:inst-1")
+	assert.Contains(t, tr.cache, "inst:inst-2")
+
+	tr.Forget("inst-2")
+	assert.Equal(t, 0, len(tr.cache))
+}
+
+func TestTenantRouter(t *testing.T) {
+	defer resetConfig()
+
+	SetConfigResolver(func(envUUID string) (Config, error) {
+		return Config{ConnStr: testConnection.ConnStr, DB: "tenants"}, nil
+	})
+
+	_, err := Insert(&testConnection, Environment{}, do.Map{"uuid": "env-2", "account_uuid": "acc-2"})
+	assert.Nil(t, err)
+	_, err = Insert(&testConnection, Instance{}, do.Map{"uuid": "inst-2", "environment_uuid": "env-2", "account_uuid": "acc-2"})
+	assert.Nil(t, err)
+
+	tr := NewTenantRouter(&testConnection)
+
+	mc, err := tr.ForInstance("inst-2")
+	assert.Nil(t, err)
+	assert.Equal(t, "env-2", mc.DBSuffix)
+	assert.Equal(t, "inst-2", mc.CollSuffix)
+
+	// Both the instance and its environment are cached
+	assert.Contains(t, tr.cache, "inst:inst-2")
+	assert.Contains(t, tr.cache, "env:env-2")
+
+	// UUIDs of one kind are not routed as the other
+	_, err = tr.ForInstance("env-2")
+	assert.NotNil(t, err)
+	_, err = tr.ForEnvironment("inst-2")
+	assert.NotNil(t, err)
+
+	_, err = tr.ForEnvironment("env-missing")
+	assert.NotNil(t, err)
+}
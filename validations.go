@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -32,11 +33,30 @@ import (
 		email:
 		rex(...)
 		enum(abc|def|ghi)
+		min(1) | max(9.5) | range(1,10)          numbers
+		len(2,8) | len(,8) | len(4)              strings (in characters)
+		url | uuid | ip | phone | alpha | alphanum
+		before(2030-01-01) | after(now)          dates
+		minitems(1) | maxitems(5) | unique       slices
+		any name given to RegisterValidator
+	  Tests on pointers check the value pointed to (nil passes), and
+	  tests other than those on slices check every item of slices
 
 */
 
 type FieldErrors map[string][]string
 
+// Error lets FieldErrors be returned as an error, listing
+// the issues of every field in a stable order
+func (fe FieldErrors) Error() string {
+	list := []string{}
+	for key, issues := range fe {
+		list = append(list, fmt.Sprintf("%s: %s", key, strings.Join(issues, ", ")))
+	}
+	sort.Strings(list)
+	return strings.Join(list, "; ")
+}
+
 func (fe FieldErrors) Add(issue string, keys ...string) {
 	finalKey := ""
 	if len(keys) > 0 {
@@ -58,9 +78,30 @@ func LoopAndAdd(st reflect.StructField, prefix string, out map[string]reflect.Ty
 	isStruct := kind == reflect.Struct
 	isTime := do.TypeIsTime(t)
 
-	if isTime || kind == reflect.Bool || kind == reflect.String ||
+	// Arrays hold values of their element's type, and arrays of
+	// sub-documents are queried by the paths of their fields
+	if kind == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		currKey := st.Tag.Get("json")
+		if currKey == "" {
+			currKey = conv.CaseSnake(st.Name)
+		}
+		if prefix != "" {
+			currKey = prefix + "." + currKey
+		}
+		elem := do.TypeDereference(t.Elem())
+		if elem.Kind() == reflect.Struct && !do.TypeIsTime(elem) {
+			for i := 0; i < elem.NumField(); i++ {
+				LoopAndAdd(elem.Field(i), currKey, out)
+			}
+		} else {
+			out[currKey] = t
+		}
+		return
+	}
+
+	if isTime || t == objectIDType || kind == reflect.Bool || kind == reflect.String ||
 		kind == reflect.Uint8 || kind == reflect.Uint16 || kind == reflect.Uint32 || kind == reflect.Uint64 ||
-		kind == reflect.Int8 || kind == reflect.Int16 || kind == reflect.Int || kind == reflect.Int64 ||
+		kind == reflect.Int8 || kind == reflect.Int16 || kind == reflect.Int || kind == reflect.Int32 || kind == reflect.Int64 ||
 		kind == reflect.Float32 || kind == reflect.Float64 {
 		currKey := st.Tag.Get("json")
 		if currKey == "" {
@@ -94,7 +135,7 @@ func StructGetFieldTypeByJsonKey(modelType interface{}, jsonFieldKey string) (re
 	// TODO:
 	// cache allFields, so for same struct you don't create it again and again
 
-	ot := do.TypeOf(modelType)
+	ot := do.TypeDereference(do.TypeOf(modelType))
 
 	for i := 0; i < ot.NumField(); i++ {
 		LoopAndAdd(ot.Field(i), "", allFields)
@@ -119,7 +160,11 @@ func TraverseModel(modelType interface{}, data do.Map, errs FieldErrors, op func
 
 		ft := do.TypeDereference(sf.Type)
 
-		if ft.Kind() == reflect.Struct && !do.TypeIsTime(ft) {
+		if sf.Anonymous && ft.Kind() == reflect.Struct && !do.TypeIsTime(ft) {
+			// Embedded structs (Timed, Deletable, ...) are stored
+			// inline, so their fields share the parent's data
+			TraverseModel(ft, data, errs, op, key...)
+		} else if ft.Kind() == reflect.Struct && !do.TypeIsTime(ft) {
 			if !data.HasKey(fname) {
 				// Pass an empty map
 				innerData := map[string]interface{}{}
@@ -161,66 +206,52 @@ func (fn FieldName) Get(f reflect.StructField) string {
 
 func verifyInputs(modelType interface{}, action int, data do.Map) []do.ErrorReference {
 	errs := []do.ErrorReference{}
+	doc := data
 
-	// Input validations as defined in 'verify' tag
-	verify := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
+	// Input validations as defined in 'verify' tag (registered
+	// validators get to see the whole document)
+	verify := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
 		if (action == INSERT || action == UPDATE) && data.HasKey(fname) {
 			checks := getFieldTests(fld)
 			for _, check := range checks {
-				if success, message := check.Verify(fld.Type, data[fname]); !success {
-					errs = append(errs, do.ErrorReference{
-						Message:   message,
-						Reference: strings.Join(keys, "."),
-					})
+				if success, message := check.VerifyIn(fld.Type, data[fname], doc); !success {
+					errs = append(errs, do.ErrorReference{Message: message, Reference: strings.Join(keys, ".")})
 				}
 			}
 		}
-		return nil
 	}
-	do.StructWalk(modelType, do.WalkConfig{"json"}, data, verify)
-	return errs
+	walkErrs := walkModel(modelType, data, verify)
+	return append(errs, walkErrs...)
 }
 
 func convertFieldType(modelType interface{}, action int, data do.Map) []do.ErrorReference {
 	errs := []do.ErrorReference{}
 
-	// Convert types of fields from STRING to appropriate type
-	// as it is specified in the Struct
-	convert := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
+	// Convert the inputs to the types of the fields (see coerce)
+	convert := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
 		inp, found := data[fname]
-		inpStr, isStr := inp.(string)
-		expType := fld.Type.String()
-
-		if found && (action == INSERT || action == UPDATE) && isStr && expType != "string" {
-			val, err := do.ParseType(inpStr, do.TypeDereference(fld.Type))
+		if found && (action == INSERT || action == UPDATE) {
+			val, err := coerce(fld.Type, inp)
 			if err == nil {
 				data[fname] = val
 			} else {
-				errs = append(errs, do.ErrorReference{
-					Message:   err.Error(),
-					Reference: strings.Join(keys, "."),
-				})
+				issue := fmt.Sprintf("field '%s' %s", fname, err)
+				errs = append(errs, do.ErrorReference{Message: issue, Reference: strings.Join(keys, ".")})
 			}
 		}
-
-		return nil
 	}
-
-	do.StructWalk(modelType, do.WalkConfig{"json"}, data, convert)
-	return errs
+	walkErrs := walkModel(modelType, data, convert)
+	return append(errs, walkErrs...)
 }
 
 func populateTimedFields(modelType interface{}, action int, data do.Map) []do.ErrorReference {
-	errs := []do.ErrorReference{}
 	isMongoStore := do.TypeComposedOf(modelType, MongoStore{})
 
 	// Manage timestamp fields (inserted_at / updated_at)
 	// during insert / update of records - do this for only
 	// MongoStores for now
-
-	// setTimed := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
 	if isMongoStore && do.TypeComposedOf(modelType, Timed{}) {
 		now := time.Now()
 		switch action {
@@ -231,18 +262,14 @@ func populateTimedFields(modelType interface{}, action int, data do.Map) []do.Er
 			data["updated_at"] = now
 		}
 	}
-	// 	return nil
-	// }
-	// do.StructWalk(modelType, do.WalkConfig{"json"}, data, setTimed)
-	return errs
+	return []do.ErrorReference{}
 }
 
 func populateAutoFields(modelType interface{}, action int, data do.Map) []do.ErrorReference {
-	errs := []do.ErrorReference{}
 	isMongoStore := do.TypeComposedOf(modelType, MongoStore{})
 
 	// Set fields marked auto - to give them appropriate value upon insertion
-	setAuto := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
+	setAuto := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
 		if action == INSERT && !data.HasKey(fname) && fld.Tag.Get("auto") != "" {
 			auto := parseAutoTag(fld)
@@ -255,45 +282,37 @@ func populateAutoFields(modelType interface{}, action int, data do.Map) []do.Err
 				}
 			}
 		}
-		return nil
 	}
-	do.StructWalk(modelType, do.WalkConfig{"json"}, data, setAuto)
-	return errs
+	return walkModel(modelType, data, setAuto)
 }
 
 func trimFields(modelType interface{}, action int, data do.Map) []do.ErrorReference {
-	errs := []do.ErrorReference{}
 
-	// Trim any input strings fields, unless markeed no (trim=no)
-	trim := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
+	// Trim any input strings fields, unless marked no (trim=no)
+	trim := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
-		if (action == INSERT || action == UPDATE) && data.HasKey(fname) && fld.Tag.Get("trim") != "no" {
+		if (action == INSERT || action == UPDATE) && data.HasKey(fname) && fld.Tag.Get("trim") != "no" && fld.Tag.Get("secret") == "" {
 			str, isString := data[fname].(string)
 			if isString {
 				data[fname] = strings.TrimSpace(str)
 			}
 		}
-		return nil
 	}
-	do.StructWalk(modelType, do.WalkConfig{"json"}, data, trim)
-	return errs
+	return walkModel(modelType, data, trim)
 }
 
 func provideDefualts(modelType interface{}, action int, data do.Map) []do.ErrorReference {
-	errs := []do.ErrorReference{}
 
 	// During inserts, if input fields are not provided and a default value is provided
 	// in the field tags then do use it
-	setDefaults := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
+	setDefaults := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
 		defStr := fld.Tag.Get("default")
 		if action == INSERT && !data.HasKey(fname) && defStr != "" && fld.Tag.Get("insert") != "no" {
 			data[fname] = defStr
 		}
-		return nil
 	}
-	do.StructWalk(modelType, do.WalkConfig{"json"}, data, setDefaults)
-	return errs
+	return walkModel(modelType, data, setDefaults)
 }
 
 func checkInsertableUpdatable(modelType interface{}, action int, data do.Map) []do.ErrorReference {
@@ -301,7 +320,7 @@ func checkInsertableUpdatable(modelType interface{}, action int, data do.Map) []
 
 	// Do validations for those fields wherein input fields are extra or
 	// input fields are expected but missing
-	checkInsertUpdate := func(fld reflect.StructField, data do.Map, keys ...string) []do.ErrorReference {
+	checkInsertUpdate := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
 
 		switch action {
@@ -320,187 +339,324 @@ func checkInsertableUpdatable(modelType interface{}, action int, data do.Map) []
 				errs = append(errs, do.ErrorReference{issue, strings.Join(keys, ".")})
 			}
 		}
-		return nil
 	}
-	do.StructWalk(modelType, do.WalkConfig{"json"}, data, checkInsertUpdate)
-	return errs
+	walkErrs := walkModel(modelType, data, checkInsertUpdate)
+	return append(errs, walkErrs...)
 }
 
-func Validate(modelType interface{}, action int, data do.Map) (success bool, issues map[string][]string) {
-	errs := FieldErrors(map[string][]string{})
-
-	isMongoStore := do.TypeComposedOf(modelType, MongoStore{})
+func hashSecretFields(modelType interface{}, action int, data do.Map) []do.ErrorReference {
+	errs := []do.ErrorReference{}
 
-	// Do validations for those fields wherein input fields are extra or
-	// input fields are expected but missing
-	checkInsertUpdate := func(fld reflect.StructField, data do.Map, keys ...string) {
+	// Hash secret fields (Validate does this only once
+	// their plain text is known to be fine)
+	hashSecrets := func(fld reflect.StructField, data do.Map, keys ...string) {
 		fname := keys[len(keys)-1]
-
-		switch action {
-		case INSERT:
-			if data.HasKey(fname) && fld.Tag.Get("insert") == "no" {
-				issue := fmt.Sprintf("field '%s' cannot be given a value (%v) upon insertion", fname, data.GetOr(fname, nil))
-				errs.Add(issue, keys...)
-			}
-			if !data.HasKey(fname) && fld.Tag.Get("insert") == "yes" {
-				issue := fmt.Sprintf("field '%s' needs a value upon insertion", fname)
-				errs.Add(issue, keys...)
+		algo := fld.Tag.Get("secret")
+		if (action == INSERT || action == UPDATE) && data.HasKey(fname) && algo != "" && len(errs) == 0 {
+			plaintext, isString := data[fname].(string)
+			if !isString {
+				issue := fmt.Sprintf("field '%s' expects a string secret", fname)
+				errs = append(errs, do.ErrorReference{Message: issue, Reference: strings.Join(keys, ".")})
+				return
 			}
-		case UPDATE:
-			if data.HasKey(fname) && fld.Tag.Get("update") == "no" {
-				issue := fmt.Sprintf("field '%s' cannot be given a value (%v) upon updation", fname, data.GetOr(fname, nil))
-				errs.Add(issue, keys...)
+			hash, err := hashSecret(algo, plaintext)
+			if err != nil {
+				errs = append(errs, do.ErrorReference{Message: err.Error(), Reference: strings.Join(keys, ".")})
+				return
 			}
+			data[fname] = hash
 		}
 	}
-	TraverseModel(modelType, data, errs, checkInsertUpdate)
+	walkErrs := walkModel(modelType, data, hashSecrets)
+	return append(errs, walkErrs...)
+}
 
-	// During inserts, if input fields are not provided and a default value is provided
-	// in the field tags then do use it
-	setDefaults := func(fld reflect.StructField, data do.Map, keys ...string) {
-		fname := keys[len(keys)-1]
-		defStr := fld.Tag.Get("default")
-		if action == INSERT && !data.HasKey(fname) && defStr != "" && fld.Tag.Get("insert") != "no" {
-			data[fname] = defStr
+// Validate runs the validation pipeline of the model (see
+// ValidationPipeline) over the data, for the action, and then
+// hashes the secret fields, if the data is fine
+func Validate(modelType interface{}, action int, data do.Map) (success bool, issues map[string][]string) {
+	errs, _ := validationPipelineOf(modelType).Run(modelType, action, data)
+	if len(errs) == 0 {
+		for _, ref := range hashSecretFields(modelType, action, data) {
+			errs.Add(ref.Message, ref.Reference)
 		}
 	}
-	TraverseModel(modelType, data, errs, setDefaults)
+	return len(errs) == 0, errs
+}
 
-	// Trim any input strings fields, unless they are off limits (trim=no)
-	trimStrings := func(fld reflect.StructField, data do.Map, keys ...string) {
-		fname := keys[len(keys)-1]
-		if (action == INSERT || action == UPDATE) && data.HasKey(fname) && fld.Tag.Get("trim") != "no" {
-			str, isString := data[fname].(string)
-			if isString {
-				data[fname] = strings.TrimSpace(str)
-			}
+type FieldTest struct {
+	Test   string
+	Option string
+}
+
+func (ft FieldTest) Verify(t reflect.Type, v interface{}) (bool, string) {
+
+	// Pointers are checked by the value they point to
+	t = do.TypeDereference(t)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return true, ""
 		}
+		rv = rv.Elem()
 	}
-	TraverseModel(modelType, data, errs, trimStrings)
-
-	// Convert types of fields from STRING to appropriate type
-	// as it is specified in the Struct
-	if isMongoStore {
-		adjustType := func(fld reflect.StructField, data do.Map, keys ...string) {
-			fname := keys[len(keys)-1]
-			inp, found := data[fname]
-			inpStr, isStr := inp.(string)
-			expType := fld.Type.String()
-			if found && (action == INSERT || action == UPDATE) && isStr && expType != "string" {
-				switch expType {
-				case "bool":
-					data[fname] = inpStr == "1" || inpStr == "yes" || inpStr == "true" || inpStr == "Y" || inpStr == "y"
-				case "int":
-					i, err := strconv.ParseInt(inpStr, 10, 32)
-					if err == nil {
-						data[fname] = int(i)
-					} else {
-						issue := fmt.Sprintf("field '%s' expects 'int' but received %s", fname, inpStr)
-						errs.Add(issue, keys...)
-					}
-				case "int64":
-					i, err := strconv.ParseInt(inpStr, 10, 64)
-					if err == nil {
-						data[fname] = i
-					} else {
-						issue := fmt.Sprintf("field '%s' expects 'int' but received %s", fname, inpStr)
-						errs.Add(issue, keys...)
-					}
-				default:
-					// TODO: panic or error
-					issue := fmt.Sprintf("unhandled field '%s' (%s : %s)", fname, expType, inpStr)
-					errs.Add(issue, keys...)
+	if !rv.IsValid() {
+		return true, ""
+	}
+	v = rv.Interface()
+
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		switch ft.Test {
+		case "minitems", "maxitems", "unique":
+			return ft.verifyItems(rv)
+		}
+		// Every item must pass
+		if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			for i := 0; i < rv.Len(); i++ {
+				if ok, message := ft.Verify(t.Elem(), rv.Index(i).Interface()); !ok {
+					return false, fmt.Sprintf("item %d: %s", i, message)
 				}
 			}
+			return true, ""
 		}
-		TraverseModel(modelType, data, errs, adjustType)
+		t = do.TypeDereference(t.Elem())
 	}
 
-	// Manage timestamp fields (inserted_at / updated_at)
-	// during insert / update of records - do this for only
-	// MongoStores
-	if isMongoStore && do.TypeComposedOf(modelType, Timed{}) {
-		now := time.Now()
-		switch action {
-		case INSERT:
-			data["created_at"] = now
-			data["updated_at"] = now
-		case UPDATE:
-			data["updated_at"] = now
+	switch {
+	case do.TypeIsTime(t):
+		return ft.verifyTime(v)
+	case t.Kind() == reflect.String:
+		return ft.verifyString(fmt.Sprintf("%v", v))
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return ft.verifyNumber(v)
+	}
+
+	return false, "validation not supported: " + ft.Test
+}
+
+func (ft FieldTest) verifyString(vstr string) (bool, string) {
+	switch ft.Test {
+	case "email":
+		if govalidator.IsEmail(vstr) {
+			return true, ""
+		} else {
+			return false, fmt.Sprintf("%s is not a valid email", vstr)
+		}
+	case "rex":
+		reg, err := regexp.Compile(ft.Option)
+		if err != nil {
+			return false, fmt.Sprintf("%s is not a valid regular expression", ft.Option)
+		}
+		if reg.MatchString(vstr) {
+			return true, ""
+		} else {
+			return false, fmt.Sprintf("%s does not match the regular expression", vstr)
+		}
+	case "enum":
+		if strings.Contains(ft.Option, "|"+vstr+"|") {
+			return true, ""
+		} else {
+			return false, fmt.Sprintf("%s must be one of predefined set", vstr)
+		}
+	case "len":
+		min, max, err := parseBounds(ft.Option, true)
+		if err != nil {
+			return false, fmt.Sprintf("%s is not a valid length", ft.Option)
+		}
+		n := float64(len([]rune(vstr)))
+		if (min != nil && n < *min) || (max != nil && n > *max) {
+			return false, fmt.Sprintf("%s must be %s characters long", vstr, describeBounds(min, max))
+		}
+		return true, ""
+	case "url":
+		if govalidator.IsRequestURL(vstr) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is not a valid url", vstr)
+	case "uuid":
+		if govalidator.IsUUID(vstr) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is not a valid uuid", vstr)
+	case "ip":
+		if govalidator.IsIP(vstr) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is not a valid ip address", vstr)
+	case "phone":
+		// E.164, allowing for the usual separators
+		digits := strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(vstr)
+		if govalidator.IsE164(digits) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is not a valid phone number", vstr)
+	case "alpha":
+		if vstr != "" && govalidator.IsAlpha(vstr) {
+			return true, ""
 		}
+		return false, fmt.Sprintf("%s must contain only letters", vstr)
+	case "alphanum":
+		if vstr != "" && govalidator.IsAlphanumeric(vstr) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s must contain only letters and digits", vstr)
+	case "before", "after":
+		// Dates held as strings
+		return ft.verifyTime(vstr)
 	}
 
-	// Set fields marked auto - to give them a value upon insertion
-	setAuto := func(fld reflect.StructField, data do.Map, keys ...string) {
-		fname := keys[len(keys)-1]
-		if action == INSERT && !data.HasKey(fname) && fld.Tag.Get("auto") != "" {
-			auto := parseAutoTag(fld)
-			if auto != nil {
-				if isMongoStore && fname == "id" && fld.Tag.Get("bson") != "" {
-					// give preference to bson
-					data[fld.Tag.Get("bson")] = auto.Generate()
-				} else {
-					data[fname] = auto.Generate()
-				}
-			}
+	return false, "validation not supported: " + ft.Test
+}
+
+func (ft FieldTest) verifyNumber(v interface{}) (bool, string) {
+	n, isNum := asFloat(v)
+	if str, isStr := v.(string); isStr {
+		f, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+		n, isNum = f, err == nil
+	}
+	if !isNum {
+		return false, fmt.Sprintf("%v is not a number", v)
+	}
+
+	var min, max *float64
+	var err error
+	switch ft.Test {
+	case "min":
+		min, _, err = parseBounds(ft.Option+",", false)
+	case "max":
+		_, max, err = parseBounds(","+ft.Option, false)
+	case "range":
+		min, max, err = parseBounds(ft.Option, false)
+	default:
+		return false, "validation not supported: " + ft.Test
+	}
+	if err != nil {
+		return false, fmt.Sprintf("%s is not a valid %s", ft.Option, ft.Test)
+	}
+
+	if (min != nil && n < *min) || (max != nil && n > *max) {
+		return false, fmt.Sprintf("%v must be %s", v, describeBounds(min, max))
+	}
+	return true, ""
+}
+
+func (ft FieldTest) verifyTime(v interface{}) (bool, string) {
+	at, isTime := v.(time.Time)
+	if str, isStr := v.(string); isStr {
+		parsed, err := coerce(timeType, str)
+		if err != nil {
+			return false, fmt.Sprintf("%s is not a valid date", str)
 		}
+		at, isTime = parsed.(time.Time), true
+	}
+	if !isTime {
+		return false, fmt.Sprintf("%v is not a valid date", v)
 	}
-	TraverseModel(modelType, data, errs, setAuto)
 
-	// Input validations as defined in 'verify' tag
-	validateInput := func(fld reflect.StructField, data do.Map, keys ...string) {
-		fname := keys[len(keys)-1]
-		if (action == INSERT || action == UPDATE) && data.HasKey(fname) {
-			checks := getFieldTests(fld)
-			for _, check := range checks {
-				if success, message := check.Verify(fld.Type, data[fname]); !success {
-					errs.Add(message, keys...)
-				}
-			}
+	limit := time.Now()
+	if ft.Option != "now" {
+		parsed, err := coerce(timeType, ft.Option)
+		if err != nil {
+			return false, fmt.Sprintf("%s is not a valid date", ft.Option)
 		}
+		limit = parsed.(time.Time)
 	}
-	TraverseModel(modelType, data, errs, validateInput)
 
-	return len(errs) == 0, errs
-}
+	switch ft.Test {
+	case "before":
+		if at.Before(limit) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s must be before %s", at.Format(time.RFC3339), ft.Option)
+	case "after":
+		if at.After(limit) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s must be after %s", at.Format(time.RFC3339), ft.Option)
+	}
 
-type FieldTest struct {
-	Test   string
-	Option string
+	return false, "validation not supported: " + ft.Test
 }
 
-func (ft FieldTest) Verify(t reflect.Type, v interface{}) (bool, string) {
+func (ft FieldTest) verifyItems(rv reflect.Value) (bool, string) {
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false, fmt.Sprintf("%v is not a list", rv.Interface())
+	}
+	n := rv.Len()
 
-	switch t.String() {
-	case "string":
-		vstr := fmt.Sprintf("%s", v)
-		switch ft.Test {
-		case "email":
-			if govalidator.IsEmail(vstr) {
-				return true, ""
-			} else {
-				return false, fmt.Sprintf("%s is not a valid email", vstr)
-			}
-		case "rex":
-			reg, err := regexp.Compile(ft.Option)
-			if err != nil {
-				return false, fmt.Sprintf("%s is not a valid regular expression", ft.Option)
-			}
-			if reg.MatchString(vstr) {
-				return true, ""
-			} else {
-				return false, fmt.Sprintf("%s does not match the regular expression", vstr)
+	switch ft.Test {
+	case "minitems", "maxitems":
+		limit, err := strconv.Atoi(strings.TrimSpace(ft.Option))
+		if err != nil {
+			return false, fmt.Sprintf("%s is not a valid number of items", ft.Option)
+		}
+		if ft.Test == "minitems" && n < limit {
+			return false, fmt.Sprintf("must have at least %d items", limit)
+		}
+		if ft.Test == "maxitems" && n > limit {
+			return false, fmt.Sprintf("must have at most %d items", limit)
+		}
+	case "unique":
+		seen := map[string]bool{}
+		for i := 0; i < n; i++ {
+			// Pointers are told apart by what they point to
+			item := rv.Index(i)
+			for (item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface) && !item.IsNil() {
+				item = item.Elem()
 			}
-		case "enum":
-			if strings.Contains(ft.Option, "|"+vstr+"|") {
-				return true, ""
-			} else {
-				return false, fmt.Sprintf("%s must be one of predefined set", vstr)
+			key := fmt.Sprintf("%#v", item.Interface())
+			if seen[key] {
+				return false, fmt.Sprintf("item %d is repeated", i)
 			}
+			seen[key] = true
+		}
+	}
+	return true, ""
+}
+
+// parseBounds parses "min,max" (either may be left out), or
+// when single is allowed, "n" as being both the min and max
+func parseBounds(option string, single bool) (min *float64, max *float64, err error) {
+	parts := strings.Split(option, ",")
+	if len(parts) == 1 && single {
+		parts = append(parts, parts[0])
+	}
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("invalid bounds %s", option)
+	}
+
+	bounds := []*float64{nil, nil}
+	for i, part := range parts {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
 		}
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		bounds[i] = &f
+	}
+	if bounds[0] == nil && bounds[1] == nil {
+		return nil, nil, fmt.Errorf("invalid bounds %s", option)
 	}
+	return bounds[0], bounds[1], nil
+}
 
-	return false, "validation not supported: " + ft.Test
+func describeBounds(min *float64, max *float64) string {
+	switch {
+	case min != nil && max != nil && *min == *max:
+		return fmt.Sprintf("%v", *min)
+	case min != nil && max != nil:
+		return fmt.Sprintf("between %v and %v", *min, *max)
+	case min != nil:
+		return fmt.Sprintf("at least %v", *min)
+	}
+	return fmt.Sprintf("at most %v", *max)
 }
 
 func getFieldTests(f reflect.StructField) (fv []FieldTest) {
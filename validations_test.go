@@ -372,3 +372,84 @@ func TestStructGetFieldTypeByJsonKey(t *testing.T) {
 	assert.Equal(t, "string", t2.String())
 	assert.Equal(t, "int", t3.String())
 }
+
+func TestStructGetFieldTypeByJsonKeyArrays(t *testing.T) {
+	a := struct {
+		Tags  []string
+		Lines []struct {
+			Qty int32 `json:"qty"`
+		} `json:"lines"`
+	}{}
+	t1, _ := StructGetFieldTypeByJsonKey(a, "tags")
+	t2, _ := StructGetFieldTypeByJsonKey(&a, "lines.qty")
+	_, found := StructGetFieldTypeByJsonKey(a, "lines")
+
+	assert.Equal(t, "[]string", t1.String())
+	assert.Equal(t, "int32", t2.String())
+	assert.False(t, found)
+}
+
+func TestVerificationsByType(t *testing.T) {
+
+	type nested struct {
+		Age     int       `verify:"range(18,60)"`
+		Score   *float64  `verify:"min(0);max(9.5)"`
+		Code    string    `verify:"len(2,4)"`
+		Pin     string    `verify:"len(4)"`
+		Site    string    `verify:"url"`
+		Key     string    `verify:"uuid"`
+		Host    *string   `verify:"ip"`
+		Mobile  string    `verify:"phone"`
+		Name    string    `verify:"alpha"`
+		Handle  string    `verify:"alphanum"`
+		Born    time.Time `verify:"before(now)"`
+		Expiry  string    `verify:"after(2020-01-01)"`
+		Tags    []string  `verify:"minitems(1);maxitems(3);unique;len(,5)"`
+		Emails  []*string `verify:"email"`
+		Ratings []int     `verify:"max(5)"`
+		Codes   []*string `verify:"unique"`
+	}
+
+	score, bad := 4.5, 10.0
+	host, badHost := "10.0.0.1", "10.0.0"
+	mail, badMail := "a@b.com", "a-at-b"
+	codeA, codeB, codeC := "x", "x", "y"
+
+	cases := []struct {
+		field string
+		pass  interface{}
+		fail  interface{}
+	}{
+		{"age", 18, "17"},
+		{"score", &score, &bad},
+		{"code", "ABC", "A"},
+		{"pin", "1234", "123"},
+		{"site", "https://example.com/x", "example"},
+		{"key", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "6ba7b810"},
+		{"host", &host, &badHost},
+		{"mobile", "+91 99778-87799", "call me"},
+		{"name", "Joe", "Joe1"},
+		{"handle", "joe1", "joe_1"},
+		{"born", time.Now().Add(-time.Hour), time.Now().Add(time.Hour)},
+		{"expiry", "2030-01-01", "2019-12-31"},
+		{"expiry", "Jan 2, 2030", "Dec 31, 2019"},
+		{"tags", []string{"a", "b"}, []string{"a", "a"}},
+		{"tags", []string{"a"}, []string{}},
+		{"tags", []string{"abcde"}, []string{"abcdef"}},
+		{"emails", []*string{&mail, nil}, []*string{&mail, &badMail}},
+		{"ratings", []int{1, 5}, []int{1, 6}},
+		{"codes", []*string{&codeA, &codeC, nil}, []*string{&codeA, &codeB}},
+	}
+
+	for _, c := range cases {
+		errs := verifyInputs(nested{}, INSERT, map[string]interface{}{c.field: c.pass})
+		assert.Equal(t, 0, len(errs), c.field, errs)
+
+		errs = verifyInputs(nested{}, INSERT, map[string]interface{}{c.field: c.fail})
+		assert.Equal(t, 1, len(errs), c.field)
+	}
+
+	// A nil pointer has nothing to verify
+	errs := verifyInputs(nested{}, INSERT, map[string]interface{}{"score": (*float64)(nil)})
+	assert.Equal(t, 0, len(errs))
+}
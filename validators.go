@@ -0,0 +1,69 @@
+package monk
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/outerjoin/do"
+)
+
+// ValidatorFunc checks the value of a field, as asked for by the verify tag
+// (eg. verify:"gst_number" or verify:"after_field(start_date)"). The option
+// is what is given within the parentheses, and data is the whole document
+// being validated, for rules spanning fields. Upon update, data holds only
+// the fields being updated. Like FieldTest.Verify, it returns false and a
+// message if the value is not valid
+type ValidatorFunc func(value interface{}, option string, data do.Map) (bool, string)
+
+// Tests handled by FieldTest.Verify itself
+var builtinTests = map[string]bool{
+	"email": true, "rex": true, "enum": true,
+	"min": true, "max": true, "range": true, "len": true,
+	"url": true, "uuid": true, "ip": true, "phone": true, "alpha": true, "alphanum": true,
+	"before": true, "after": true,
+	"minitems": true, "maxitems": true, "unique": true,
+}
+
+var validators = struct {
+	sync.RWMutex
+	byName map[string]ValidatorFunc
+}{
+	byName: map[string]ValidatorFunc{},
+}
+
+// RegisterValidator makes the validator usable in verify tags by the
+// name. Names of the built in tests cannot be taken, while registering
+// a name again replaces the validator
+func RegisterValidator(name string, fn ValidatorFunc) error {
+	if name == "" || fn == nil {
+		return fmt.Errorf("validator needs a name and a function")
+	}
+	if builtinTests[name] {
+		return fmt.Errorf("validator '%s' is built in", name)
+	}
+
+	validators.Lock()
+	defer validators.Unlock()
+	validators.byName[name] = fn
+	return nil
+}
+
+func registeredValidator(name string) (ValidatorFunc, bool) {
+	validators.RLock()
+	defer validators.RUnlock()
+	fn, found := validators.byName[name]
+	return fn, found
+}
+
+// VerifyIn verifies the value just like Verify, but also runs
+// registered validators, giving them the whole document
+func (ft FieldTest) VerifyIn(t reflect.Type, v interface{}, data do.Map) (bool, string) {
+	if !builtinTests[ft.Test] {
+		if fn, found := registeredValidator(ft.Test); found {
+			return fn(v, ft.Option, data)
+		}
+		return false, fmt.Sprintf("unknown validation: %s", ft.Test)
+	}
+	return ft.Verify(t, v)
+}
@@ -0,0 +1,58 @@
+package monk
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/outerjoin/do"
+	"github.com/stretchr/testify/assert"
+)
+
+type validatorsTest struct {
+	GST       string    `json:"gst" verify:"gst_number"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date" verify:"after_field(start_date)"`
+	Misc      string    `json:"misc" verify:"no_such_check"`
+}
+
+func TestRegisterValidator(t *testing.T) {
+
+	assert.Nil(t, RegisterValidator("gst_number", func(value interface{}, option string, data do.Map) (bool, string) {
+		if str, ok := value.(string); ok && len(str) == 15 {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%v is not a valid GST number", value)
+	}))
+	assert.Nil(t, RegisterValidator("after_field", func(value interface{}, option string, data do.Map) (bool, string) {
+		end, _ := value.(time.Time)
+		start, found := data[option].(time.Time)
+		if !found || end.After(start) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("must be after %s", option)
+	}))
+
+	// Built in tests cannot be replaced
+	assert.NotNil(t, RegisterValidator("email", func(interface{}, string, do.Map) (bool, string) { return true, "" }))
+	assert.NotNil(t, RegisterValidator("x", nil))
+
+	now := time.Now()
+	ok, issues := Validate(validatorsTest{}, INSERT, do.Map{
+		"gst":        "27AAPFU0939F1ZV",
+		"start_date": now,
+		"end_date":   now.Add(time.Hour),
+	})
+	assert.True(t, ok, issues)
+
+	ok, issues = Validate(validatorsTest{}, INSERT, do.Map{
+		"gst":        "27AAP",
+		"start_date": now,
+		"end_date":   now.Add(-time.Hour),
+		"misc":       "x",
+	})
+	assert.False(t, ok)
+	assert.Equal(t, []string{"27AAP is not a valid GST number"}, issues["gst"])
+	assert.Equal(t, []string{"must be after start_date"}, issues["end_date"])
+	assert.Equal(t, []string{"unknown validation: no_such_check"}, issues["misc"])
+}